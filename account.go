@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CoinEfficiency compares what the daily-revenue estimates promised for a coin
+// with what the pool actually credited over the same stretch of history.
+type CoinEfficiency struct {
+	Ticker    string
+	Mined     time.Duration // time spent mining the coin
+	Estimated float64       // coin expected from the estimates while mining it
+	Actual    float64       // coin credited by the pool
+}
+
+// Ratio returns Actual/Estimated; ok is false when nothing was estimated.
+func (e CoinEfficiency) Ratio() (ratio float64, ok bool) {
+	if e.Estimated <= 0 {
+		return 0, false
+	}
+	return e.Actual / e.Estimated, true
+}
+
+// efficiencyOf walks consecutive snapshots that both carry the pool's Earned
+// total for a coin. The increase counts as actual earnings whatever was mined
+// (pools credit with a delay); the estimate accrues only while the earlier
+// snapshot was mining the coin. A decrease means the pool reset its counters
+// or the wallet changed, and that interval is skipped.
+func efficiencyOf(snaps []Snapshot) []CoinEfficiency {
+	m := make(map[string]*CoinEfficiency)
+	for i := 1; i < len(snaps); i++ {
+		prev, cur := snaps[i-1], snaps[i]
+		dt := cur.Time.Sub(prev.Time)
+		for t, now := range cur.Earned {
+			before, ok := prev.Earned[t]
+			if !ok || now < before {
+				continue
+			}
+			e, ok := m[t]
+			if !ok {
+				e = &CoinEfficiency{Ticker: t}
+				m[t] = e
+			}
+			e.Actual += now - before
+			if prev.Mining == t {
+				e.Mined += dt
+				e.Estimated += prev.CoinRevenue[t] * dt.Hours() / 24
+			}
+		}
+	}
+	out := make([]CoinEfficiency, 0, len(m))
+	for _, e := range m {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Ticker < out[j].Ticker })
+	return out
+}
+
+// writeEfficiency prints the estimate-vs-actual table; nothing when effs is empty.
+func writeEfficiency(w io.Writer, effs []CoinEfficiency) {
+	if len(effs) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  Estimated vs credited by the pool")
+	fmt.Fprintln(w, strings.Repeat("─", 72))
+	fmt.Fprintf(w, "  %-8s  %8s  %18s  %18s  %10s\n", "Coin", "Mined", "Estimated (coin)", "Credited (coin)", "Efficiency")
+	fmt.Fprintln(w, strings.Repeat("─", 72))
+	for _, e := range effs {
+		ratio := "—"
+		if r, ok := e.Ratio(); ok {
+			ratio = fmt.Sprintf("%.1f%%", r*100)
+		}
+		fmt.Fprintf(w, "  %-8s  %8s  %18.8f  %18.8f  %10s\n", e.Ticker, formatSpan(e.Mined), e.Estimated, e.Actual, ratio)
+	}
+	fmt.Fprintln(w, strings.Repeat("─", 72))
+}
+
+// formatSpan renders a duration in minutes, hours, or days past two days.
+func formatSpan(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%.1f d", d.Hours()/24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%.1f h", d.Hours())
+	default:
+		return fmt.Sprintf("%.0f m", d.Minutes())
+	}
+}
+
+// shortWallet abbreviates long wallet addresses for tables.
+func shortWallet(w string) string {
+	if len(w) <= 16 {
+		return w
+	}
+	return w[:8] + "..." + w[len(w)-5:]
+}
+
+// cmdAccount implements the `account` subcommand: live pool stats for every
+// coin with a wallet, and how the estimates compared with the pool's credits
+// over the stored history.
+func cmdAccount(args []string) int {
+	fs := flag.NewFlagSet("account", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	since := fs.String("since", "7d", "Start of the efficiency range: a duration back from now or a date (2006-01-02)")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+	cfg, err := loadConfig(*configPath, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	httpClient = cfg.httpClient
+
+	now := time.Now()
+	from, err := parseTimeArg(*since, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-since: %v\n", err)
+		return 1
+	}
+
+	if cfg.KryptexStatsURL == "" {
+		fmt.Fprintln(os.Stderr, "kryptex_stats_url is not set")
+		return 1
+	}
+	w := styled(os.Stdout)
+	n := 0
+	for _, c := range cfg.Coins {
+		if c.Wallet == "" {
+			continue
+		}
+		if n == 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintf(w, "  %-8s  %-16s  %18s  %18s  %14s\n", "Coin", "Wallet", "Unpaid", "Paid", "Pool hashrate")
+			fmt.Fprintln(w, strings.Repeat("─", 86))
+		}
+		n++
+		stats, err := fetchMinerStats(cfg.KryptexStatsURL, c.Ticker, c.Wallet)
+		if err != nil {
+			fmt.Fprintf(w, "  %-8s  %-16s  %v\n", c.Ticker, shortWallet(c.Wallet), err)
+			continue
+		}
+		fmt.Fprintf(w, "  %-8s  %-16s  %18.8f  %18.8f  %14s\n",
+			c.Ticker, shortWallet(c.Wallet), stats.Unpaid, stats.Paid, formatHashrate(stats.Hashrate))
+	}
+	if n == 0 {
+		fmt.Fprintln(os.Stderr, "no coin has a wallet configured")
+		return 1
+	}
+	fmt.Fprintln(w, strings.Repeat("─", 86))
+
+	snaps, err := loadSnapshots(cfg, from, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	effs := efficiencyOf(snaps)
+	if len(effs) == 0 {
+		fmt.Fprintf(w, "\n  No pool earnings recorded since %s\n\n", from.Format(time.DateTime))
+		return 0
+	}
+	writeEfficiency(w, effs)
+	fmt.Fprintln(w)
+	return 0
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+)
+
+// AnomalyPolicy holds the outlier limits for revenue estimates. Both limits
+// are off at zero.
+type AnomalyPolicy struct {
+	Ratio   float64 // reject values more than Ratio times above or below the recent median
+	ZScore  float64 // reject values more than ZScore standard deviations from the recent mean
+	Window  int     // recent snapshots compared against
+	Confirm int     // consecutive outlying cycles after which the new level is accepted
+}
+
+func anomalyPolicyFromConfig(cfg *Config) AnomalyPolicy {
+	return AnomalyPolicy{
+		Ratio:   cfg.AnomalyRatio,
+		ZScore:  cfg.AnomalyZScore,
+		Window:  cfg.AnomalyWindow,
+		Confirm: cfg.AnomalyConfirm,
+	}
+}
+
+// anomalyMinSamples is the least history needed to judge a value.
+const anomalyMinSamples = 3
+
+// AnomalyGuard quarantines revenue estimates that jump away from a coin's
+// recent history. Values are compared as revenue per H/s so that hashrate
+// changes do not look like anomalies. A coin keeps being rejected until its
+// new level has held for Policy.Confirm consecutive cycles.
+type AnomalyGuard struct {
+	Policy AnomalyPolicy
+
+	hist    *History
+	mu      sync.Mutex
+	pending map[string]int // ticker -> consecutive outlying cycles
+}
+
+func NewAnomalyGuard(p AnomalyPolicy, hist *History) *AnomalyGuard {
+	return &AnomalyGuard{Policy: p, hist: hist, pending: make(map[string]int)}
+}
+
+// Check returns an error explaining why p's revenue is quarantined, or nil
+// when it is consistent with history, confirmed, or cannot be judged. A nil
+// guard accepts everything.
+func (g *AnomalyGuard) Check(p CoinProfitability, hashrate int) error {
+	if g == nil || (g.Policy.Ratio <= 0 && g.Policy.ZScore <= 0) || hashrate <= 0 {
+		return nil
+	}
+	recent := recentRevenueRates(g.hist.All(), p.Ticker, g.Policy.Window)
+	why := g.Policy.outlier(p.DailyRevCoin/float64(hashrate), recent)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if why == "" {
+		delete(g.pending, p.Ticker)
+		return nil
+	}
+	g.pending[p.Ticker]++
+	n := g.pending[p.Ticker]
+	if n >= g.Policy.Confirm {
+		delete(g.pending, p.Ticker)
+		slog.Warn("Revenue anomaly confirmed, accepting the new level", "ticker", p.Ticker, "cycles", n, "reason", why)
+		return nil
+	}
+	return fmt.Errorf("revenue anomaly (%d/%d cycles to confirm): %s", n, g.Policy.Confirm, why)
+}
+
+// markUnconfirmed flags the estimates in profs that break the policy against
+// hist. One-shot commands use it instead of a guard: they run a single cycle,
+// so a guard would quarantine every outlier without a chance to confirm it.
+func (p AnomalyPolicy) markUnconfirmed(profs []CoinProfitability, hist *History, hashrate int) {
+	if (p.Ratio <= 0 && p.ZScore <= 0) || hashrate <= 0 {
+		return
+	}
+	for i := range profs {
+		recent := recentRevenueRates(hist.All(), profs[i].Ticker, p.Window)
+		profs[i].Unconfirmed = p.outlier(profs[i].DailyRevCoin/float64(hashrate), recent) != ""
+	}
+}
+
+// outlier describes how v breaks the policy against recent values, or returns
+// "" when it does not or there are too few values to tell.
+func (p AnomalyPolicy) outlier(v float64, recent []float64) string {
+	if len(recent) < anomalyMinSamples {
+		return ""
+	}
+	if p.Ratio > 0 {
+		med := median(recent)
+		if med > 0 && (v > med*p.Ratio || v < med/p.Ratio) {
+			return fmt.Sprintf("%.2fx the recent median (limit %gx)", v/med, p.Ratio)
+		}
+	}
+	if p.ZScore > 0 {
+		m := mean(recent)
+		var ss float64
+		for _, r := range recent {
+			ss += (r - m) * (r - m)
+		}
+		// A flat history would make any change infinitely unlikely: assume at least 1% noise.
+		sd := math.Max(math.Sqrt(ss/float64(len(recent))), m*0.01)
+		if z := (v - m) / sd; sd > 0 && math.Abs(z) > p.ZScore {
+			return fmt.Sprintf("z-score %.1f against the recent mean (limit %g)", z, p.ZScore)
+		}
+	}
+	return ""
+}
+
+// recentRevenueRates returns ticker's daily revenue per H/s from the last
+// window snapshots that recorded it.
+func recentRevenueRates(snaps []Snapshot, ticker string, window int) []float64 {
+	var out []float64
+	for i := len(snaps) - 1; i >= 0 && len(out) < window; i-- {
+		s := snaps[i]
+		if rev, ok := s.CoinRevenue[ticker]; ok && s.Hashrate > 0 {
+			out = append(out, rev/s.Hashrate)
+		}
+	}
+	return out
+}
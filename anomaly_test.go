@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func anomalyHistory(revs ...float64) *History {
+	h := NewHistory(100)
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, r := range revs {
+		// recorded at another hashrate: compared per H/s
+		h.Add(Snapshot{Time: t0.Add(time.Duration(i) * 5 * time.Minute), Hashrate: 2000, CoinRevenue: map[string]float64{"XMR": r}})
+	}
+	return h
+}
+
+func TestAnomalyGuardConfirm(t *testing.T) {
+	g := NewAnomalyGuard(AnomalyPolicy{Ratio: 3, Window: 12, Confirm: 3}, anomalyHistory(0.002, 0.0021, 0.0019, 0.002))
+	normal := CoinProfitability{Ticker: "XMR", DailyRevCoin: 0.001}
+	spike := CoinProfitability{Ticker: "XMR", DailyRevCoin: 0.01}
+
+	if err := g.Check(normal, 1000); err != nil {
+		t.Fatalf("normal value rejected: %v", err)
+	}
+	err := g.Check(spike, 1000)
+	if err == nil || !strings.Contains(err.Error(), "1/3") || !strings.Contains(err.Error(), "10.00x") {
+		t.Fatalf("spike: err = %v, want a 10x anomaly at 1/3", err)
+	}
+	// back to normal resets the count
+	if err := g.Check(normal, 1000); err != nil {
+		t.Fatalf("normal value rejected: %v", err)
+	}
+	for i := 1; i <= 2; i++ {
+		if err := g.Check(spike, 1000); err == nil {
+			t.Fatalf("spike accepted after %d cycles", i)
+		}
+	}
+	if err := g.Check(spike, 1000); err != nil {
+		t.Errorf("spike still rejected on the confirming cycle: %v", err)
+	}
+	// a drop is an anomaly too
+	if err := g.Check(CoinProfitability{Ticker: "XMR", DailyRevCoin: 0.0001}, 1000); err == nil {
+		t.Error("a 10x drop was accepted")
+	}
+}
+
+func TestAnomalyPolicyOutlier(t *testing.T) {
+	recent := []float64{1, 1.02, 0.98, 1.01, 0.99}
+	tests := []struct {
+		name   string
+		policy AnomalyPolicy
+		v      float64
+		recent []float64
+		want   string
+	}{
+		{"within ratio", AnomalyPolicy{Ratio: 3}, 2.5, recent, ""},
+		{"above ratio", AnomalyPolicy{Ratio: 3}, 3.5, recent, "3.50x the recent median"},
+		{"below ratio", AnomalyPolicy{Ratio: 3}, 0.2, recent, "0.20x the recent median"},
+		{"within z", AnomalyPolicy{ZScore: 4}, 1.04, recent, ""},
+		{"beyond z", AnomalyPolicy{ZScore: 4}, 1.2, recent, "z-score"},
+		{"flat history uses 1% noise", AnomalyPolicy{ZScore: 4}, 1.03, []float64{1, 1, 1}, ""},
+		{"too little history", AnomalyPolicy{Ratio: 3}, 100, []float64{1, 1}, ""},
+	}
+	for _, tt := range tests {
+		got := tt.policy.outlier(tt.v, tt.recent)
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("%s: outlier = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnomalyGuardOff(t *testing.T) {
+	spike := CoinProfitability{Ticker: "XMR", DailyRevCoin: 1}
+	var nilGuard *AnomalyGuard
+	if err := nilGuard.Check(spike, 1000); err != nil {
+		t.Errorf("nil guard: %v", err)
+	}
+	g := NewAnomalyGuard(AnomalyPolicy{Window: 12, Confirm: 3}, anomalyHistory(0.002, 0.002, 0.002))
+	if err := g.Check(spike, 1000); err != nil {
+		t.Errorf("guard without limits: %v", err)
+	}
+}
+
+func TestAnomalyPolicyMarkUnconfirmed(t *testing.T) {
+	profs := []CoinProfitability{{Ticker: "XMR", DailyRevCoin: 0.01}, {Ticker: "XMR", DailyRevCoin: 0.001}}
+	AnomalyPolicy{Ratio: 3, Window: 12, Confirm: 3}.markUnconfirmed(profs, anomalyHistory(0.002, 0.0021, 0.0019, 0.002), 1000)
+	if !profs[0].Unconfirmed || profs[0].dataStatus() != "unconfirmed" {
+		t.Errorf("spike: unconfirmed %v, status %q; want it kept and marked", profs[0].Unconfirmed, profs[0].dataStatus())
+	}
+	if profs[1].Unconfirmed {
+		t.Error("normal value marked unconfirmed")
+	}
+}
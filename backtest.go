@@ -0,0 +1,275 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BacktestResult summarizes one policy replayed over a history.
+type BacktestResult struct {
+	Policy   Policy
+	Earnings float64 // estimated fiat earned over the replayed period
+	Switches int
+}
+
+// backtest replays snaps through a Decider using policy p. Each snapshot's
+// revenue for the chosen coin is credited for the time it stands for (see
+// spanDays), given the raw poll interval.
+func backtest(snaps []Snapshot, p Policy, interval time.Duration) BacktestResult {
+	d := NewDecider(p)
+	res := BacktestResult{Policy: p}
+	for i, s := range snaps {
+		dec := d.Decide(s.Time, s.Coins)
+		if dec.Switch {
+			if d.Current != "" {
+				res.Switches++
+			}
+			d.Commit(dec.Ticker, s.Time)
+		}
+		res.Earnings += s.Coins[d.Current] * spanDays(snaps, i, interval)
+	}
+	return res
+}
+
+// switching returns the part of p the backtest varies: the payout preferences
+// have no effect on a replay, which has no pool balances. Smoothing windows of
+// 1 or less all mean "latest only" and are normalised to 1.
+func (p Policy) switching() Policy {
+	return Policy{Threshold: p.Threshold, MinDwell: p.MinDwell, Smoothing: max(p.Smoothing, 1)}
+}
+
+// policyGrid returns every combination of the given thresholds, dwell times
+// and smoothing windows, without duplicates, plus configured if it is not
+// already among them.
+func policyGrid(ths []float64, dws []time.Duration, sms []int, configured *Policy) []Policy {
+	var out []Policy
+	seen := make(map[Policy]bool)
+	add := func(p Policy) {
+		if p = p.switching(); !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	for _, th := range ths {
+		for _, dw := range dws {
+			for _, sm := range sms {
+				add(Policy{Threshold: th, MinDwell: dw, Smoothing: sm})
+			}
+		}
+	}
+	if configured != nil {
+		add(*configured)
+	}
+	return out
+}
+
+// fixedEarnings returns what mining each coin for the whole period would have
+// earned, with the same crediting rule as backtest.
+func fixedEarnings(snaps []Snapshot, interval time.Duration) map[string]float64 {
+	out := make(map[string]float64)
+	for i, s := range snaps {
+		days := spanDays(snaps, i, interval)
+		for t, v := range s.Coins {
+			out[t] += v * days
+		}
+	}
+	return out
+}
+
+// spanDays is the time in days credited to snapshot i. An aggregate from the
+// history store stands for its samples, each one poll interval long. A raw
+// snapshot is credited until the next snapshot, at most three intervals so
+// downtime is not counted as mining; the last one gets one interval.
+func spanDays(snaps []Snapshot, i int, interval time.Duration) float64 {
+	s := snaps[i]
+	if s.Samples > 0 {
+		return (time.Duration(s.Samples) * interval).Hours() / 24
+	}
+	d := interval
+	if i+1 < len(snaps) {
+		d = min(snaps[i+1].Time.Sub(s.Time), 3*interval)
+	}
+	return d.Hours() / 24
+}
+
+// rawInterval returns the median spacing between consecutive raw (not
+// aggregated) snapshots, or 0 if there are no two in a row.
+func rawInterval(snaps []Snapshot) time.Duration {
+	var gaps []time.Duration
+	for i := 1; i < len(snaps); i++ {
+		if snaps[i].Samples == 0 && snaps[i-1].Samples == 0 {
+			gaps = append(gaps, snaps[i].Time.Sub(snaps[i-1].Time))
+		}
+	}
+	if len(gaps) == 0 {
+		return 0
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	return gaps[len(gaps)/2]
+}
+
+// cmdBacktest implements the `backtest` subcommand: replay stored history
+// through every combination of the given thresholds, dwell times and smoothing
+// windows and compare against the best single coin.
+func cmdBacktest(args []string) int {
+	fs := flag.NewFlagSet("backtest", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	historyPath := fs.String("history", "", "History file to replay (default: history_db, else history_file from the config)")
+	since := fs.String("since", "", "Start of the range: a duration back from now (24h, 30d) or a date (default: everything)")
+	until := fs.String("until", "", "End of the range, same formats as -since (default: now)")
+	thresholds := fs.String("thresholds", "0,1,2,5", "Comma-separated switch thresholds in percent")
+	dwells := fs.String("dwell", "0,30m,2h", "Comma-separated minimum dwell times")
+	smoothings := fs.String("smoothing", "1,3,6", "Comma-separated smoothing windows in samples")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+
+	ths, err := parseList(*thresholds, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-thresholds: %v\n", err)
+		return 1
+	}
+	dws, err := parseList(*dwells, func(s string) (time.Duration, error) {
+		if s == "0" {
+			return 0, nil
+		}
+		return time.ParseDuration(s)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-dwell: %v\n", err)
+		return 1
+	}
+	sms, err := parseList(*smoothings, strconv.Atoi)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-smoothing: %v\n", err)
+		return 1
+	}
+
+	now := time.Now()
+	var from time.Time
+	to := now.Add(time.Second)
+	if *since != "" {
+		if from, err = parseTimeArg(*since, now); err != nil {
+			fmt.Fprintf(os.Stderr, "-since: %v\n", err)
+			return 1
+		}
+	}
+	if *until != "" {
+		if to, err = parseTimeArg(*until, now); err != nil {
+			fmt.Fprintf(os.Stderr, "-until: %v\n", err)
+			return 1
+		}
+	}
+
+	var snaps []Snapshot
+	fiat := "USD"
+	var configured *Policy
+	pollInterval := 300 * time.Second // the config default
+	if *historyPath != "" {
+		all, err := readHistoryFile(*historyPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		for _, s := range all {
+			if !s.Time.Before(from) && s.Time.Before(to) {
+				snaps = append(snaps, s)
+			}
+		}
+	} else {
+		cfg, err := loadConfig(*configPath, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		if snaps, err = loadSnapshots(cfg, from, to); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		fiat = cfg.FiatCurrency
+		pollInterval = time.Duration(cfg.Interval) * time.Second
+		p := policyFromConfig(cfg).switching()
+		configured = &p
+	}
+	if len(snaps) < 2 {
+		fmt.Println("Not enough history to backtest (need at least 2 snapshots)")
+		return 1
+	}
+
+	// Aggregates from history_db are credited per sample, so the interval
+	// comes from the raw points (or the config when all are aggregated)
+	interval := rawInterval(snaps)
+	if interval == 0 {
+		interval = pollInterval
+	}
+	var results []BacktestResult
+	for _, p := range policyGrid(ths, dws, sms, configured) {
+		results = append(results, backtest(snaps, p, interval))
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Earnings > results[j].Earnings })
+
+	fixed := fixedEarnings(snaps, interval)
+	bestFixed := ""
+	for t, v := range fixed {
+		if bestFixed == "" || v > fixed[bestFixed] || (v == fixed[bestFixed] && t < bestFixed) {
+			bestFixed = t
+		}
+	}
+	base := fixed[bestFixed]
+
+	out := styled(os.Stdout)
+	currency := strings.ToUpper(fiat)
+	first, last := snaps[0].Time, snaps[len(snaps)-1].Time
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "  Backtest %s → %s (%d snapshots)\n", first.Format(time.DateTime), last.Format(time.DateTime), len(snaps))
+	fmt.Fprintf(out, "  Best fixed coin: %s = %.8f %s\n", bestFixed, base, currency)
+	fmt.Fprintln(out, strings.Repeat("─", 79))
+	fmt.Fprintf(out, "  %-4s  %10s  %8s  %9s  %16s  %8s  %12s\n",
+		"Rank", "Threshold", "Dwell", "Smoothing", fmt.Sprintf("Earned (%s)", currency), "Switches", "vs fixed")
+	fmt.Fprintln(out, strings.Repeat("─", 79))
+	for i, r := range results {
+		marker := "  "
+		if configured != nil && *configured == r.Policy {
+			marker = "★ "
+		}
+		var vs string
+		if base > 0 {
+			vs = fmt.Sprintf("%+.2f%%", (r.Earnings-base)/base*100)
+		}
+		fmt.Fprintf(out, "  %-4d  %s%7.1f%%  %8s  %9d  %16.8f  %8d  %12s\n",
+			i+1, marker, r.Policy.Threshold, r.Policy.MinDwell, r.Policy.Smoothing, r.Earnings, r.Switches, vs)
+	}
+	fmt.Fprintln(out, strings.Repeat("─", 79))
+	if configured != nil {
+		fmt.Fprintln(out, "  ★ = policy from config")
+	}
+	fmt.Fprintln(out)
+	return 0
+}
+
+func parseList[T any](s string, parse func(string) (T, error)) ([]T, error) {
+	var out []T
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := parse(part)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("empty list")
+	}
+	return out, nil
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"math"
+	"os"
 	"sort"
 	"strings"
+	"time"
 )
 
 var coinColors = []string{
@@ -18,20 +21,74 @@ var coinColors = []string{
 	"\033[38;5;255m", // white
 }
 
+// coinRGB are the hex equivalents of coinColors for the image renderers.
+var coinRGB = []string{"#ff0000", "#00ff00", "#0087ff", "#ffff00", "#ff8700", "#ff00ff", "#00ffff", "#eeeeee"}
+
 const colorReset = "\033[0m"
 const colorDim = "\033[2m"
 const colorBold = "\033[1m"
 
-func printChart(hist *History, fiat string) {
-	snaps := hist.All()
+// chartOptions controls what writeChart plots. The zero value plots daily
+// fiat revenue on 60 columns and 15 rows.
+type chartOptions struct {
+	BTC    bool          // plot BTC/MH/day (Snapshot.CoinsBTC) instead of fiat
+	Window time.Duration // only plot snapshots this close to the newest one (0 = all)
+	Width  int           // plot columns (0 = 60); longer series are bucketed to fit
+	Height int           // plot rows (0 = 15)
+}
+
+// series returns the values plotted for s.
+func (o chartOptions) series(s Snapshot) map[string]float64 {
+	if o.BTC {
+		return s.CoinsBTC
+	}
+	return s.Coins
+}
+
+// printChart plots daily revenue per coin. A zero opts.Width fits the chart
+// to the terminal.
+func printChart(snaps []Snapshot, fiat string, opts chartOptions) {
+	if opts.Width == 0 {
+		if cols, _, ok := terminalSize(); ok {
+			opts.Width = max(cols-16, 20)
+		}
+	}
+	writeChart(styled(os.Stdout), snaps, fiat, opts)
+}
+
+// chartData is the renderer-independent content of a chart: the columns to
+// plot, the coins and their colors, and the padded value range. writeChart
+// draws it with terminal characters, writeChartSVG and writeChartPNG as images.
+type chartData struct {
+	Unit    string     // e.g. "EUR/day" or "BTC/MH/day"
+	Tickers []string   // sorted; Tickers[i] is drawn with color i
+	Snaps   []Snapshot // one per column
+	Min     float64
+	Max     float64
+	opts    chartOptions
+}
+
+// newChartData selects and buckets snaps for opts. It returns nil when there
+// are fewer than two columns to plot.
+func newChartData(snaps []Snapshot, fiat string, opts chartOptions) *chartData {
+	if opts.Window > 0 && len(snaps) > 0 {
+		from := snaps[len(snaps)-1].Time.Add(-opts.Window)
+		i := sort.Search(len(snaps), func(i int) bool { return !snaps[i].Time.Before(from) })
+		snaps = snaps[i:]
+	}
 	if len(snaps) < 2 {
-		return
+		return nil
+	}
+	width := opts.Width
+	if width <= 0 {
+		width = 60
 	}
+	snaps = fitColumns(snaps, width)
 
 	// Collect all tickers (stable order)
 	tickerSet := make(map[string]bool)
 	for _, s := range snaps {
-		for t := range s.Coins {
+		for t := range opts.series(s) {
 			tickerSet[t] = true
 		}
 	}
@@ -41,24 +98,11 @@ func printChart(hist *History, fiat string) {
 	}
 	sort.Strings(tickers)
 
-	colorMap := make(map[string]string)
-	for i, t := range tickers {
-		colorMap[t] = coinColors[i%len(coinColors)]
-	}
-
-	// Chart dimensions
-	chartWidth := len(snaps)
-	if chartWidth > 60 {
-		chartWidth = 60
-		snaps = snaps[len(snaps)-60:]
-	}
-	chartHeight := 15
-
 	// Find global min/max
 	minVal := math.MaxFloat64
 	maxVal := -math.MaxFloat64
 	for _, s := range snaps {
-		for _, v := range s.Coins {
+		for _, v := range opts.series(s) {
 			if v < minVal {
 				minVal = v
 			}
@@ -67,17 +111,66 @@ func printChart(hist *History, fiat string) {
 			}
 		}
 	}
+	if len(tickers) == 0 {
+		minVal, maxVal = 0, 0
+	}
 
-	// Add top padding and clamp bottom to 0
+	// Zoom on the data with 5% padding, without going below 0 for positive data
 	valRange := maxVal - minVal
 	if valRange == 0 {
-		valRange = 1
+		valRange = math.Abs(maxVal) * 0.1
+		if valRange == 0 {
+			valRange = 1
+		}
 	}
+	positive := minVal >= 0
 	maxVal += valRange * 0.05
-	if minVal > 0 {
+	minVal -= valRange * 0.05
+	if positive && minVal < 0 {
 		minVal = 0
 	}
-	valRange = maxVal - minVal
+
+	unit := strings.ToUpper(fiat) + "/day"
+	if opts.BTC {
+		unit = "BTC/MH/day"
+	}
+	return &chartData{Unit: unit, Tickers: tickers, Snaps: snaps, Min: minVal, Max: maxVal, opts: opts}
+}
+
+// Value returns the value of ticker in column col.
+func (c *chartData) Value(col int, ticker string) (float64, bool) {
+	v, ok := c.opts.series(c.Snaps[col])[ticker]
+	return v, ok
+}
+
+// TimeLabels returns the labels of the first and last column.
+func (c *chartData) TimeLabels() (string, string) {
+	first, last := c.Snaps[0].Time, c.Snaps[len(c.Snaps)-1].Time
+	layout := "15:04"
+	if last.Sub(first) >= 24*time.Hour {
+		layout = "01-02 15:04"
+	}
+	return first.Format(layout), last.Format(layout)
+}
+
+// writeChart renders the chart printed by printChart.
+func writeChart(w io.Writer, snaps []Snapshot, fiat string, opts chartOptions) {
+	data := newChartData(snaps, fiat, opts)
+	if data == nil {
+		return
+	}
+	snaps, tickers := data.Snaps, data.Tickers
+	colorMap := make(map[string]string)
+	for i, t := range tickers {
+		colorMap[t] = coinColors[i%len(coinColors)]
+	}
+
+	chartWidth, chartHeight := len(snaps), opts.Height
+	if chartHeight <= 0 {
+		chartHeight = 15
+	}
+	minVal, maxVal := data.Min, data.Max
+	valRange := maxVal - minVal
 
 	// Build grid: grid[row][col] = character to print
 	// row 0 = top (maxVal), row chartHeight-1 = bottom (minVal)
@@ -97,29 +190,17 @@ func printChart(hist *History, fiat string) {
 	for _, ticker := range tickers {
 		color := colorMap[ticker]
 		for col, s := range snaps {
-			v, ok := s.Coins[ticker]
+			v, ok := data.Value(col, ticker)
 			if !ok {
 				continue
 			}
-			row := int(float64(chartHeight-1) * (1 - (v-minVal)/valRange))
-			if row < 0 {
-				row = 0
-			}
-			if row >= chartHeight {
-				row = chartHeight - 1
-			}
+			row := valueRow(v, minVal, valRange, chartHeight)
 			ch := '─'
 			// Connect with rounded corners if previous point was at a different row
 			if col > 0 {
-				prevV, ok := snaps[col-1].Coins[ticker]
+				prevV, ok := data.Value(col-1, ticker)
 				if ok {
-					prevRow := int(float64(chartHeight-1) * (1 - (prevV-minVal)/valRange))
-					if prevRow < 0 {
-						prevRow = 0
-					}
-					if prevRow >= chartHeight {
-						prevRow = chartHeight - 1
-					}
+					prevRow := valueRow(prevV, minVal, valRange, chartHeight)
 					if prevRow > row {
 						// Going UP: line ascends from prevRow to row
 						// ╭─  (row: arrival, corner DOWN+RIGHT)
@@ -171,48 +252,88 @@ func printChart(hist *History, fiat string) {
 	}
 
 	// Render
-	currency := strings.ToUpper(fiat)
-	fmt.Printf("\n  %sProfitability Chart (%s/day)%s\n", colorBold, currency, colorReset)
+	labelFmt := axisFormat(valRange / float64(chartHeight-1))
+	fmt.Fprintf(w, "\n  %sProfitability Chart (%s)%s\n", colorBold, data.Unit, colorReset)
 
 	for r := 0; r < chartHeight; r++ {
 		// Y-axis label (5 positions: top, middle, bottom)
 		val := maxVal - float64(r)/float64(chartHeight-1)*valRange
 		if r == 0 || r == chartHeight-1 || r == chartHeight/2 {
-			fmt.Printf("  %10.6f │", val)
+			fmt.Fprintf(w, labelFmt, val)
 		} else {
-			fmt.Printf("             │")
+			fmt.Fprintf(w, "             │")
 		}
 		for c := 0; c < chartWidth; c++ {
 			cl := grid[r][c]
 			if cl.color != "" {
-				fmt.Printf("%s%c%s", cl.color, cl.char, colorReset)
+				fmt.Fprintf(w, "%s%c%s", cl.color, cl.char, colorReset)
 			} else {
-				fmt.Printf("%c", cl.char)
+				fmt.Fprintf(w, "%c", cl.char)
 			}
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 
 	// X-axis
-	fmt.Printf("             └")
-	fmt.Print(strings.Repeat("─", chartWidth))
-	fmt.Println()
+	fmt.Fprintf(w, "             └")
+	fmt.Fprint(w, strings.Repeat("─", chartWidth))
+	fmt.Fprintln(w)
 
 	// Time labels
-	if len(snaps) > 0 {
-		first := snaps[0].Time.Format("15:04")
-		last := snaps[len(snaps)-1].Time.Format("15:04")
-		pad := chartWidth - len(first) - len(last)
-		if pad < 1 {
-			pad = 1
-		}
-		fmt.Printf("              %s%s%s\n", first, strings.Repeat(" ", pad), last)
+	first, last := data.TimeLabels()
+	pad := chartWidth - len(first) - len(last)
+	if pad < 1 {
+		pad = 1
 	}
+	fmt.Fprintf(w, "              %s%s%s\n", first, strings.Repeat(" ", pad), last)
 
 	// Legend
-	fmt.Print("  ")
+	fmt.Fprint(w, "  ")
 	for _, t := range tickers {
-		fmt.Printf(" %s●%s %s", colorMap[t], colorReset, t)
+		fmt.Fprintf(w, " %s●%s %s", colorMap[t], colorReset, t)
+	}
+	fmt.Fprintf(w, "   %s┊%s = switch\n\n", colorDim, colorReset)
+}
+
+// fitColumns returns snaps unchanged if they fit in width columns, and
+// otherwise aggregates them into at most width equal time buckets.
+func fitColumns(snaps []Snapshot, width int) []Snapshot {
+	if len(snaps) <= width {
+		return snaps
+	}
+	span := snaps[len(snaps)-1].Time.Sub(snaps[0].Time)
+	bucket := (span/time.Duration(width) + time.Second).Truncate(time.Second)
+	out := aggregate(snaps, bucket)
+	// Bucket boundaries are aligned to the clock, which can add one column.
+	for len(out) > width {
+		bucket += bucket / 10
+		out = aggregate(snaps, bucket)
+	}
+	return out
+}
+
+// axisFormat returns a y-axis label format with enough decimals to tell rows
+// step apart, and at least the 6 used for fiat amounts.
+func axisFormat(step float64) string {
+	decimals := 6
+	if step > 0 {
+		decimals = max(decimals, int(math.Ceil(-math.Log10(step)))+1)
+	}
+	if decimals > 8 {
+		return "  %10.3e │"
+	}
+	return fmt.Sprintf("  %%10.%df │", decimals)
+}
+
+// valueRow maps v to a grid row: 0 is the top (minVal+valRange), height-1 the
+// bottom (minVal). Out-of-range values are clamped.
+func valueRow(v, minVal, valRange float64, height int) int {
+	row := int(float64(height-1) * (1 - (v-minVal)/valRange))
+	if row < 0 {
+		row = 0
+	}
+	if row >= height {
+		row = height - 1
 	}
-	fmt.Printf("   %s┊%s = switch\n\n", colorDim, colorReset)
+	return row
 }
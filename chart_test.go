@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata/")
+
+// checkGolden compares got with testdata/name, rewriting it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run go test -update to accept):\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+// testSnapshots returns a small deterministic history with one switch.
+func testSnapshots() []Snapshot {
+	base := time.Date(2026, 2, 23, 17, 48, 0, 0, time.UTC)
+	fiat := []map[string]float64{
+		{"XTM": 0.25, "SAL": 0.27, "XMR": 0.24},
+		{"XTM": 0.26, "SAL": 0.27, "XMR": 0.23},
+		{"XTM": 0.28, "SAL": 0.26, "XMR": 0.24},
+		{"XTM": 0.30, "SAL": 0.26, "XMR": 0.23},
+		{"XTM": 0.31, "SAL": 0.25, "XMR": 0.24},
+	}
+	mining := []string{"SAL", "SAL", "XTM", "XTM", "XTM"}
+	var snaps []Snapshot
+	for i, f := range fiat {
+		btc := make(map[string]float64, len(f))
+		for t, v := range f {
+			btc[t] = v / 600
+		}
+		snaps = append(snaps, Snapshot{
+			Time:     base.Add(time.Duration(i) * 5 * time.Minute),
+			Coins:    f,
+			CoinsBTC: btc,
+			Mining:   mining[i],
+			Switched: i == 2,
+		})
+	}
+	return snaps
+}
+
+func TestValueRow(t *testing.T) {
+	tests := []struct {
+		v, min, rng float64
+		height      int
+		want        int
+	}{
+		{v: 10, min: 0, rng: 10, height: 15, want: 0},  // top
+		{v: 0, min: 0, rng: 10, height: 15, want: 14},  // bottom
+		{v: 5, min: 0, rng: 10, height: 15, want: 7},   // middle
+		{v: 20, min: 0, rng: 10, height: 15, want: 0},  // clamped above
+		{v: -5, min: 0, rng: 10, height: 15, want: 14}, // clamped below
+		{v: 1.5, min: 1, rng: 1, height: 3, want: 1},
+	}
+	for _, tt := range tests {
+		if got := valueRow(tt.v, tt.min, tt.rng, tt.height); got != tt.want {
+			t.Errorf("valueRow(%g, %g, %g, %d) = %d, want %d", tt.v, tt.min, tt.rng, tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestWriteChartGolden(t *testing.T) {
+	var buf bytes.Buffer
+	writeChart(&buf, testSnapshots(), "eur", chartOptions{})
+	checkGolden(t, "chart.golden", buf.Bytes())
+}
+
+func TestWriteChartTooFewSnapshots(t *testing.T) {
+	var buf bytes.Buffer
+	writeChart(&buf, testSnapshots()[:1], "eur", chartOptions{})
+	if buf.Len() != 0 {
+		t.Errorf("expected no output for a single snapshot, got %q", buf.String())
+	}
+}
+
+func TestWriteChartBucketsLongSeries(t *testing.T) {
+	var snaps []Snapshot
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		snaps = append(snaps, Snapshot{Time: base.Add(time.Duration(i) * time.Minute), Coins: map[string]float64{"XMR": float64(i)}})
+	}
+	var buf bytes.Buffer
+	writeChart(&buf, snaps, "usd", chartOptions{})
+	// the whole series is bucketed into 60 columns instead of truncated
+	lines := strings.Split(buf.String(), "\n")
+	for _, l := range lines {
+		if strings.Contains(l, "└") {
+			if n := strings.Count(l, "─"); n > 60 {
+				t.Errorf("chart is %d columns wide, want at most 60", n)
+			}
+		}
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("00:00")) {
+		t.Errorf("time labels do not start at the first snapshot:\n%s", buf.String())
+	}
+}
+
+func TestWriteChartAutoZoom(t *testing.T) {
+	snaps := testSnapshots()
+	var buf bytes.Buffer
+	writeChart(&buf, snaps, "eur", chartOptions{})
+	// values range 0.23-0.31: the bottom label must not be 0
+	if strings.Contains(buf.String(), "0.000000 │") {
+		t.Errorf("y-axis still clamped to 0:\n%s", buf.String())
+	}
+}
+
+func TestAxisFormat(t *testing.T) {
+	tests := []struct {
+		step float64
+		want string
+	}{
+		{0.01, "  %10.6f │"},
+		{0.0000004, "  %10.8f │"},
+		{0.00000001, "  %10.3e │"},
+	}
+	for _, tt := range tests {
+		if got := axisFormat(tt.step); got != tt.want {
+			t.Errorf("axisFormat(%g) = %q, want %q", tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestStyledWriterPlainASCII(t *testing.T) {
+	var buf bytes.Buffer
+	writeChart(styledWriter{w: &buf, style: outputStyle{Color: false, ASCII: true}}, testSnapshots(), "eur", chartOptions{})
+	if buf.Len() == 0 {
+		t.Fatal("no chart output")
+	}
+	for i, b := range buf.Bytes() {
+		if b == 0x1b || b > 0x7e {
+			t.Fatalf("non-ASCII or escape byte %#x at offset %d:\n%s", b, i, buf.String())
+		}
+	}
+}
+
+func TestStyledWriterKeepsUnicodeWithoutColor(t *testing.T) {
+	var buf bytes.Buffer
+	writeChart(styledWriter{w: &buf, style: outputStyle{Color: false}}, testSnapshots(), "eur", chartOptions{})
+	if bytes.IndexByte(buf.Bytes(), 0x1b) >= 0 {
+		t.Errorf("escape codes left in uncolored output:\n%s", buf.String())
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("│")) {
+		t.Errorf("box-drawing characters missing:\n%s", buf.String())
+	}
+}
+
+func TestWriteChartOptions(t *testing.T) {
+	snaps := testSnapshots()
+	var buf bytes.Buffer
+	writeChart(&buf, snaps, "eur", chartOptions{BTC: true, Height: 5})
+	out := buf.String()
+	if !strings.Contains(out, "BTC/MH/day") {
+		t.Errorf("BTC chart title missing:\n%s", out)
+	}
+	if n := strings.Count(out, "│"); n != 5 {
+		t.Errorf("got %d plot rows, want 5:\n%s", n, out)
+	}
+
+	buf.Reset()
+	window := snaps[len(snaps)-1].Time.Sub(snaps[len(snaps)-3].Time)
+	writeChart(&buf, snaps, "eur", chartOptions{Window: window})
+	first := snaps[len(snaps)-3].Time.Format("15:04")
+	if !strings.Contains(buf.String(), "  "+first) {
+		t.Errorf("window does not start at %s:\n%s", first, buf.String())
+	}
+}
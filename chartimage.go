@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Image chart colors (dark background so the terminal palette stays readable).
+const (
+	imgBackground = "#1e1e1e"
+	imgForeground = "#dddddd"
+	imgGrid       = "#3a3a3a"
+	imgSwitch     = "#808080"
+)
+
+// chartLayout maps chart columns and values to pixels of a width x height
+// image. Both image renderers use it so SVG and PNG output line up.
+type chartLayout struct {
+	data                     *chartData
+	width, height            int
+	left, right, top, bottom int // plot area
+}
+
+func newChartLayout(data *chartData, width, height int) chartLayout {
+	return chartLayout{
+		data: data, width: width, height: height,
+		left: 110, right: width - 20, top: 40, bottom: height - 60,
+	}
+}
+
+// X returns the horizontal position of column col.
+func (l chartLayout) X(col int) int {
+	n := len(l.data.Snaps)
+	if n < 2 {
+		return l.left
+	}
+	return l.left + col*(l.right-l.left)/(n-1)
+}
+
+// Y returns the vertical position of value v.
+func (l chartLayout) Y(v float64) int {
+	frac := (v - l.data.Min) / (l.data.Max - l.data.Min)
+	return l.bottom - int(frac*float64(l.bottom-l.top)+0.5)
+}
+
+// axisLabels returns the y-axis label values: top, middle and bottom.
+func (l chartLayout) axisLabels() []float64 {
+	return []float64{l.data.Max, (l.data.Max + l.data.Min) / 2, l.data.Min}
+}
+
+func (l chartLayout) axisLabel(v float64) string {
+	step := (l.data.Max - l.data.Min) / 14
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(fmt.Sprintf(axisFormat(step), v), "  "), " │"))
+}
+
+// defaultImageSize is used when no image size is configured.
+const (
+	defaultImageWidth  = 960
+	defaultImageHeight = 480
+)
+
+// imageColumns is how many columns fit in an image: one every 4 pixels.
+func imageColumns(width int) int {
+	return max((width-130)/4, 2)
+}
+
+// writeChartImage renders snaps to path as SVG or PNG depending on the file
+// extension. The file is replaced atomically.
+func writeChartImage(path string, snaps []Snapshot, fiat string, opts chartOptions, width, height int) error {
+	if width <= 0 {
+		width = defaultImageWidth
+	}
+	if height <= 0 {
+		height = defaultImageHeight
+	}
+	opts.Width = imageColumns(width)
+	data := newChartData(snaps, fiat, opts)
+	if data == nil {
+		return fmt.Errorf("not enough history for a chart")
+	}
+	var buf bytes.Buffer
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".svg":
+		writeChartSVG(&buf, data, width, height)
+	case ".png":
+		if err := writeChartPNG(&buf, data, width, height); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported chart image %q (use .svg or .png)", path)
+	}
+	return writeFileAtomic(path, buf.Bytes(), 0644)
+}
+
+// writeChartSVG renders data as an SVG document.
+func writeChartSVG(w io.Writer, data *chartData, width, height int) {
+	l := newChartLayout(data, width, height)
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="12">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", imgBackground)
+	fmt.Fprintf(w, `<text x="%d" y="24" fill="%s" font-size="14" font-weight="bold">Profitability Chart (%s)</text>`+"\n",
+		l.left, imgForeground, html.EscapeString(data.Unit))
+
+	// Grid and y-axis labels
+	for _, v := range l.axisLabels() {
+		y := l.Y(v)
+		fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n", l.left, y, l.right, y, imgGrid)
+		fmt.Fprintf(w, `<text x="%d" y="%d" fill="%s" text-anchor="end">%s</text>`+"\n", l.left-8, y+4, imgForeground, l.axisLabel(v))
+	}
+
+	// Switch markers
+	for col, s := range data.Snaps {
+		if s.Switched {
+			x := l.X(col)
+			fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-dasharray="4 4"/>`+"\n", x, l.top, x, l.bottom, imgSwitch)
+		}
+	}
+
+	// One polyline per run of consecutive values, dots where the coin was mined
+	for i, t := range data.Tickers {
+		rgb := coinRGB[i%len(coinRGB)]
+		var points []string
+		flush := func() {
+			if len(points) > 1 {
+				fmt.Fprintf(w, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`+"\n", rgb, strings.Join(points, " "))
+			}
+			points = points[:0]
+		}
+		for col := range data.Snaps {
+			v, ok := data.Value(col, t)
+			if !ok {
+				flush()
+				continue
+			}
+			points = append(points, strconv.Itoa(l.X(col))+","+strconv.Itoa(l.Y(v)))
+		}
+		flush()
+		for col, s := range data.Snaps {
+			if v, ok := data.Value(col, t); ok && s.Mining == t {
+				fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="3.5" fill="%s"/>`+"\n", l.X(col), l.Y(v), rgb)
+			}
+		}
+	}
+
+	// X-axis, time labels and legend
+	fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n", l.left, l.bottom, l.right, l.bottom, imgForeground)
+	first, last := data.TimeLabels()
+	fmt.Fprintf(w, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", l.left, l.bottom+18, imgForeground, first)
+	fmt.Fprintf(w, `<text x="%d" y="%d" fill="%s" text-anchor="end">%s</text>`+"\n", l.right, l.bottom+18, imgForeground, last)
+	x := l.left
+	for i, t := range data.Tickers {
+		fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="4" fill="%s"/>`+"\n", x+4, height-18, coinRGB[i%len(coinRGB)])
+		fmt.Fprintf(w, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", x+14, height-14, imgForeground, html.EscapeString(t))
+		x += 24 + 8*len(t)
+	}
+	fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-dasharray="4 4"/>`+"\n", x+4, height-26, x+4, height-10, imgSwitch)
+	fmt.Fprintf(w, `<text x="%d" y="%d" fill="%s">= switch</text>`+"\n", x+14, height-14, imgForeground)
+	fmt.Fprintln(w, "</svg>")
+}
+
+// writeChartPNG renders data as a PNG image, drawing text with a built-in
+// bitmap font so no font files are needed.
+func writeChartPNG(w io.Writer, data *chartData, width, height int) error {
+	l := newChartLayout(data, width, height)
+	c := &canvas{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+	c.fill(hexColor(imgBackground))
+	fg := hexColor(imgForeground)
+
+	c.text(l.left, 14, "Profitability Chart ("+data.Unit+")", fg)
+	for _, v := range l.axisLabels() {
+		y := l.Y(v)
+		c.hline(l.left, l.right, y, hexColor(imgGrid))
+		label := l.axisLabel(v)
+		c.text(l.left-8-textWidth(label), y-glyphHeight/2, label, fg)
+	}
+	for col, s := range data.Snaps {
+		if s.Switched {
+			c.dashedVLine(l.X(col), l.top, l.bottom, hexColor(imgSwitch))
+		}
+	}
+	for i, t := range data.Tickers {
+		rgb := hexColor(coinRGB[i%len(coinRGB)])
+		prevX, prevY, prevOK := 0, 0, false
+		for col := range data.Snaps {
+			v, ok := data.Value(col, t)
+			if !ok {
+				prevOK = false
+				continue
+			}
+			x, y := l.X(col), l.Y(v)
+			if prevOK {
+				c.line(prevX, prevY, x, y, rgb)
+			}
+			prevX, prevY, prevOK = x, y, true
+		}
+		for col, s := range data.Snaps {
+			if v, ok := data.Value(col, t); ok && s.Mining == t {
+				c.dot(l.X(col), l.Y(v), 4, rgb)
+			}
+		}
+	}
+
+	c.hline(l.left, l.right, l.bottom, fg)
+	first, last := data.TimeLabels()
+	c.text(l.left, l.bottom+8, first, fg)
+	c.text(l.right-textWidth(last), l.bottom+8, last, fg)
+	x := l.left
+	for i, t := range data.Tickers {
+		c.dot(x+4, height-18, 4, hexColor(coinRGB[i%len(coinRGB)]))
+		c.text(x+14, height-25, t, fg)
+		x += 24 + textWidth(t)
+	}
+	c.dashedVLine(x+4, height-26, height-10, hexColor(imgSwitch))
+	c.text(x+14, height-25, "= switch", fg)
+	return png.Encode(w, c.img)
+}
+
+func hexColor(s string) color.RGBA {
+	v, _ := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
+
+// canvas is a minimal raster drawing surface.
+type canvas struct {
+	img *image.RGBA
+}
+
+func (c *canvas) fill(col color.RGBA) {
+	b := c.img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c.img.SetRGBA(x, y, col)
+		}
+	}
+}
+
+func (c *canvas) set(x, y int, col color.RGBA) {
+	if image.Pt(x, y).In(c.img.Bounds()) {
+		c.img.SetRGBA(x, y, col)
+	}
+}
+
+func (c *canvas) hline(x0, x1, y int, col color.RGBA) {
+	for x := x0; x <= x1; x++ {
+		c.set(x, y, col)
+	}
+}
+
+func (c *canvas) dashedVLine(x, y0, y1 int, col color.RGBA) {
+	for y := y0; y <= y1; y++ {
+		if (y-y0)%8 < 4 {
+			c.set(x, y, col)
+		}
+	}
+}
+
+// line draws a 2-pixel wide line with Bresenham's algorithm.
+func (c *canvas) line(x0, y0, x1, y1 int, col color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		c.set(x0, y0, col)
+		c.set(x0+1, y0, col)
+		c.set(x0, y0+1, col)
+		c.set(x0+1, y0+1, col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			x0 += sx
+		} else {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func (c *canvas) dot(cx, cy, r int, col color.RGBA) {
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			if x*x+y*y <= r*r {
+				c.set(cx+x, cy+y, col)
+			}
+		}
+	}
+}
+
+// Built-in 5x7 font, drawn at twice its size.
+const (
+	glyphScale   = 2
+	glyphAdvance = 6 * glyphScale
+	glyphHeight  = 7 * glyphScale
+)
+
+func textWidth(s string) int {
+	return len([]rune(s)) * glyphAdvance
+}
+
+// text draws s with its top-left corner at x, y. Letters are drawn in upper
+// case; characters without a glyph are left blank.
+func (c *canvas) text(x, y int, s string, col color.RGBA) {
+	for _, r := range strings.ToUpper(s) {
+		rows := font5x7[r]
+		for row, bits := range rows {
+			for bit := 0; bit < 5; bit++ {
+				if bits&(0x10>>bit) == 0 {
+					continue
+				}
+				for dy := 0; dy < glyphScale; dy++ {
+					for dx := 0; dx < glyphScale; dx++ {
+						c.set(x+bit*glyphScale+dx, y+row*glyphScale+dy, col)
+					}
+				}
+			}
+		}
+		x += glyphAdvance
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// font5x7 holds one 5-bit row mask per line, most significant bit on the left.
+var font5x7 = map[rune][7]uint8{
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'A': {0x0E, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'B': {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C': {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D': {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G': {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H': {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I': {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M': {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P': {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q': {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R': {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S': {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T': {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X': {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	',': {0x00, 0x00, 0x00, 0x00, 0x0C, 0x04, 0x08},
+	':': {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'+': {0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00},
+	'=': {0x00, 0x00, 0x1F, 0x00, 0x1F, 0x00, 0x00},
+	'/': {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'_': {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F},
+	'(': {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},
+	')': {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+}
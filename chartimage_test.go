@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteChartSVG(t *testing.T) {
+	data := newChartData(testSnapshots(), "eur", chartOptions{Width: 200})
+	var buf bytes.Buffer
+	writeChartSVG(&buf, data, 960, 480)
+	svg := buf.String()
+
+	if !strings.HasPrefix(svg, "<svg ") || !strings.HasSuffix(svg, "</svg>\n") {
+		t.Fatalf("not an SVG document:\n%s", svg)
+	}
+	if n := strings.Count(svg, "<polyline"); n != 3 {
+		t.Errorf("got %d polylines, want one per coin (3)", n)
+	}
+	// 5 mined points plus one legend dot per coin
+	if n := strings.Count(svg, "<circle"); n != 5+3 {
+		t.Errorf("got %d circles, want 8", n)
+	}
+	// one switch in the data plus the legend sample
+	if n := strings.Count(svg, `stroke-dasharray="4 4"`); n != 2 {
+		t.Errorf("got %d dashed lines, want 2", n)
+	}
+	if !strings.Contains(svg, "Profitability Chart (EUR/day)") {
+		t.Error("title missing")
+	}
+}
+
+func TestWriteChartPNG(t *testing.T) {
+	data := newChartData(testSnapshots(), "eur", chartOptions{BTC: true, Width: 200})
+	var buf bytes.Buffer
+	if err := writeChartPNG(&buf, data, 640, 320); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 640 || b.Dy() != 320 {
+		t.Errorf("image is %dx%d, want 640x320", b.Dx(), b.Dy())
+	}
+	// the mined XTM dot of the last column is drawn in its coin color
+	l := newChartLayout(data, 640, 320)
+	last := len(data.Snaps) - 1
+	v, _ := data.Value(last, "XTM")
+	if got, want := img.At(l.X(last), l.Y(v)), hexColor(coinRGB[2]); got != want {
+		t.Errorf("pixel at last XTM point = %v, want %v", got, want)
+	}
+}
+
+func TestWriteChartImage(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"chart.svg", "chart.png"} {
+		if err := writeChartImage(filepath.Join(dir, name), testSnapshots(), "eur", chartOptions{}, 0, 0); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if err := writeChartImage(filepath.Join(dir, "chart.gif"), testSnapshots(), "eur", chartOptions{}, 0, 0); err == nil {
+		t.Error("expected an error for .gif")
+	}
+	if err := writeChartImage(filepath.Join(dir, "empty.svg"), nil, "eur", chartOptions{}, 0, 0); err == nil {
+		t.Error("expected an error without history")
+	}
+}
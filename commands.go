@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cmdStatus implements the `status` subcommand: one profitability table,
+// with averages and the mined coin taken from the history file.
+func cmdStatus(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+	cfg, err := loadConfig(*configPath, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	httpClient = cfg.httpClient
+
+	hist := NewHistory((86400 / cfg.Interval) + 1)
+	current := ""
+	if err := hist.Load(cfg.HistoryFile); err == nil {
+		if snaps := hist.All(); len(snaps) > 0 {
+			current = snaps[len(snaps)-1].Mining
+		}
+	}
+
+	hashrate, _ := currentHashrate(cfg, hist)
+	if hashrate == 0 {
+		fmt.Fprintln(os.Stderr, "no hashrate available: the live hashrate failed and hashrate_fallback has no value")
+		return 1
+	}
+	profs, err := computeProfitability(cfg, hashrate, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	anomalyPolicyFromConfig(cfg).markUnconfirmed(profs, hist, hashrate)
+	known := NewLastKnown(cfg.lastKnownAge)
+	known.Prime(hist.All(), cfg.Coins)
+	profs = known.Fill(time.Now(), profs, cfg.Coins, hashrate)
+	printTable(profs, cfg.FiatCurrency, current, hist, hashrate)
+	return 0
+}
+
+// cmdWorkers implements the `workers` subcommand.
+func cmdWorkers(args []string) int {
+	fs := flag.NewFlagSet("workers", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	all := fs.Bool("all", false, "List workers of every algorithm, not only proxy_algorithm")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+	cfg, err := loadConfig(*configPath, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	httpClient = cfg.httpClient
+
+	algo := cfg.ProxyAlgorithm
+	if *all {
+		algo = ""
+	}
+	workers, err := fetchAllWorkers(cfg.ProxyBaseURL, cfg.ProxyAPIKey, algo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].Name < workers[j].Name })
+
+	coinByProfile := profileTickers(cfg)
+	out := styled(os.Stdout)
+	var total uint64
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "  %-20s  %-26s  %-8s  %-10s  %14s  %s\n", "Name", "ID", "Status", "Algorithm", "Hashrate", "Profile")
+	fmt.Fprintln(out, strings.Repeat("─", 100))
+	for _, w := range workers {
+		profile := w.ProfileID
+		if t, ok := coinByProfile[w.ProfileID]; ok {
+			profile = fmt.Sprintf("%s (%s)", w.ProfileID, t)
+		}
+		fmt.Fprintf(out, "  %-20s  %-26s  %-8s  %-10s  %14s  %s\n",
+			w.Name, w.ID, w.Status, w.Algorithm, formatHashrate(float64(w.Hashrate)), profile)
+		total += w.Hashrate
+	}
+	fmt.Fprintln(out, strings.Repeat("─", 100))
+	fmt.Fprintf(out, "  %d worker(s), %s total\n\n", len(workers), formatHashrate(float64(total)))
+	return 0
+}
+
+// cmdProfiles implements the `profiles` subcommand.
+func cmdProfiles(args []string) int {
+	fs := flag.NewFlagSet("profiles", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+	cfg, err := loadConfig(*configPath, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	httpClient = cfg.httpClient
+
+	profiles, err := fetchAllProfiles(cfg.ProxyBaseURL, cfg.ProxyAPIKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	coinByProfile := profileTickers(cfg)
+	out := styled(os.Stdout)
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "  %-2s  %-26s  %-30s  %-10s  %s\n", "", "ID", "Name", "Algorithm", "Coin")
+	fmt.Fprintln(out, strings.Repeat("─", 84))
+	for _, p := range profiles {
+		marker := "  "
+		if p.IsDefault {
+			marker = "★ "
+		}
+		fmt.Fprintf(out, "  %s  %-26s  %-30s  %-10s  %s\n", marker, p.ID, p.Name, p.Algorithm, coinByProfile[p.ID])
+	}
+	fmt.Fprintln(out, strings.Repeat("─", 84))
+	fmt.Fprintln(out, "  ★ = default profile")
+	fmt.Fprintln(out)
+	return 0
+}
+
+// cmdSwitch implements the `switch <ticker>` subcommand: a one-off manual
+// switch of every worker, also making the coin's profile the default.
+func cmdSwitch(args []string) int {
+	fs := flag.NewFlagSet("switch", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: ultimate-proxy-profile-switcher switch [flags] <ticker>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	cfg, err := loadConfig(*configPath, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	httpClient = cfg.httpClient
+
+	ticker := strings.ToUpper(fs.Arg(0))
+	coin, ok := cfg.coin(ticker)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s is not configured\n", ticker)
+		return 1
+	}
+
+	if err := setDefaultProfile(cfg.ProxyBaseURL, cfg.ProxyAPIKey, coin.ProfileID); err != nil {
+		fmt.Fprintf(os.Stderr, "set default profile: %v\n", err)
+		return 1
+	}
+	n, err := switchWorkers(cfg, coin.ProfileID, coin.Ticker)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	fmt.Printf("Switched %d worker(s) to %s (profile %s)\n", n, coin.Ticker, coin.ProfileID)
+	return 0
+}
+
+// cmdRates implements the `rates` subcommand: the raw Kryptex /rates data.
+func cmdRates(args []string) int {
+	fs := flag.NewFlagSet("rates", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	asJSON := fs.Bool("json", false, "Print the response as JSON")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+	cfg, err := loadConfig(*configPath, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	httpClient = cfg.httpClient
+
+	rates, err := fetchRates(cfg.KryptexBaseURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rates)
+		return 0
+	}
+	configured := make(map[string]bool)
+	for _, c := range cfg.Coins {
+		configured[c.Ticker] = true
+	}
+	out := styled(os.Stdout)
+	printRates := func(title string, m map[string]float64, mark map[string]bool) {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(out, "\n  %s (USD)\n", title)
+		fmt.Fprintln(out, strings.Repeat("─", 32))
+		for _, k := range keys {
+			marker := "  "
+			if mark[k] {
+				marker = "★ "
+			}
+			fmt.Fprintf(out, "  %s%-8s  %18.8f\n", marker, k, m[k])
+		}
+	}
+	printRates("Fiat", rates.Fiat, map[string]bool{strings.ToUpper(cfg.FiatCurrency): true})
+	printRates("Crypto", rates.Crypto, configured)
+	fmt.Fprintln(out, strings.Repeat("─", 32))
+	fmt.Fprintln(out, "  ★ = configured")
+	if len(cfg.priceSources) > 0 {
+		providers := append([]PriceProvider{kryptexPrices{rates}}, cfg.priceSources...)
+		tickers := []string{"BTC"}
+		for _, c := range cfg.Coins {
+			tickers = append(tickers, c.Ticker)
+		}
+		writePriceSources(out, providers, fetchPrices(providers, tickers), tickers, cfg.PriceMaxSpread)
+	}
+	fmt.Fprintln(out)
+	return 0
+}
+
+// writePriceSources prints each source's price per ticker next to the median
+// and spread, marking tickers whose sources disagree beyond maxSpread.
+func writePriceSources(w io.Writer, providers []PriceProvider, quotes map[string]PriceQuote, tickers []string, maxSpread float64) {
+	width := 8 + 16*len(providers) + 16 + 10 + 10
+	fmt.Fprintf(w, "\n  Price sources (USD)\n")
+	fmt.Fprintln(w, strings.Repeat("─", width))
+	fmt.Fprintf(w, "  %-6s", "Coin")
+	for _, p := range providers {
+		fmt.Fprintf(w, "  %14s", p.Name())
+	}
+	fmt.Fprintf(w, "  %14s  %8s  %8s\n", "Median", "Spread", "Status")
+	fmt.Fprintln(w, strings.Repeat("─", width))
+	for _, t := range tickers {
+		q, ok := quotes[t]
+		fmt.Fprintf(w, "  %-6s", t)
+		for _, p := range providers {
+			if v, ok := q.Sources[p.Name()]; ok {
+				fmt.Fprintf(w, "  %14.6f", v)
+			} else {
+				fmt.Fprintf(w, "  %14s", "—")
+			}
+		}
+		status := "ok"
+		switch {
+		case !ok:
+			status = "no price"
+		case q.Disagree(maxSpread):
+			status = "disagree"
+		}
+		fmt.Fprintf(w, "  %14.6f  %7.1f%%  %8s\n", q.Price, q.Spread, status)
+	}
+	fmt.Fprintln(w, strings.Repeat("─", width))
+}
+
+// profileTickers maps configured profile IDs to their coin tickers.
+func profileTickers(cfg *Config) map[string]string {
+	m := make(map[string]string, len(cfg.Coins))
+	for _, c := range cfg.Coins {
+		m[c.ProfileID] = c.Ticker
+	}
+	return m
+}
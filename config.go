@@ -1,45 +1,160 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// envPrefix is prepended to the upper-cased YAML key to form the environment
+// variable overriding a config field (e.g. PROFSWITCH_PROXY_API_KEY).
+const envPrefix = "PROFSWITCH_"
+
 type CoinConfig struct {
 	Ticker        string `yaml:"ticker"`
 	RevenueTicker string `yaml:"revenue_ticker,omitempty"` // override for /daily-revenue/ endpoint (e.g. XTM_rx)
 	ProfileID     string `yaml:"profile_id"`
+
+	PayoutThreshold float64 `yaml:"payout_threshold,omitempty"` // pool payout minimum, in coin units
+	UnpaidBalance   float64 `yaml:"unpaid_balance,omitempty"`   // current unpaid balance, entered manually
+	BalanceURL      string  `yaml:"balance_url,omitempty"`      // pool API returning the unpaid balance as JSON
+	BalanceField    string  `yaml:"balance_field,omitempty"`    // dot path to the balance in that JSON (e.g. data.unpaid)
+	Wallet          string  `yaml:"wallet,omitempty"`           // Kryptex wallet or account mining this coin, for pool stats
 }
 
 type Config struct {
-	ProxyBaseURL    string
-	KryptexBaseURL  string       `yaml:"kryptex_base_url"`
-	ProxyAPIKey     string       `yaml:"proxy_api_key"`
-	ProxyAlgorithm  string       `yaml:"proxy_algorithm"` // algorithm used to list workers (e.g. kawpow, randomx)
-	FiatCurrency    string       `yaml:"fiat_currency"`
-	Interval        int          `yaml:"interval"`
-	DefaultHashrate int          `yaml:"default_hashrate"`
-	HistoryFile     string       `yaml:"history_file"` // path to persist history (default: profswitch_history.json)
-	Coins           []CoinConfig `yaml:"coins"`
+	ProxyBaseURL     string              `yaml:"proxy_base_url"`
+	KryptexBaseURL   string              `yaml:"kryptex_base_url"`
+	KryptexStatsURL  string              `yaml:"kryptex_stats_url"` // miner stats endpoint, {coin} and {wallet} are filled in (empty = no pool stats)
+	CABundle         string              `yaml:"ca_bundle"`         // extra PEM CA certificates trusted by both API clients
+	HTTPProxy        string              `yaml:"http_proxy"`        // proxy URL for both API clients (default: HTTP(S)_PROXY env)
+	HTTPCache        map[string]string   `yaml:"http_cache"`        // URL substring -> time to live of cached GET responses
+	HTTPCacheDir     string              `yaml:"http_cache_dir"`    // share cached responses with other instances through this directory
+	HTTPMaxStale     string              `yaml:"http_max_stale"`    // serve cached responses up to this old when the API fails (default 1h)
+	HTTPRateLimit    float64             `yaml:"http_rate_limit"`   // requests per second per API host (0 = unlimited)
+	ProxyAPIKey      string              `yaml:"proxy_api_key"`
+	ProxyAPIKeyFile  string              `yaml:"proxy_api_key_file"` // read the API key from this file (e.g. a mounted secret)
+	ProxyAlgorithm   string              `yaml:"proxy_algorithm"`    // algorithm used to list workers (e.g. kawpow, randomx)
+	FiatCurrency     string              `yaml:"fiat_currency"`
+	Interval         int                 `yaml:"interval"`
+	DefaultHashrate  int                 `yaml:"default_hashrate"`
+	HashrateRange    string              `yaml:"hashrate_range"`    // time range of the hashrate stats: 1h, 6h, 24h or 7d (default 1h)
+	HashrateStat     string              `yaml:"hashrate_stat"`     // avg, peak or current (sum of the workers' live hashrate)
+	HashrateFallback []string            `yaml:"hashrate_fallback"` // sources tried in order when the live hashrate fails (default: history, default)
+	HistoryFile      string              `yaml:"history_file"`      // path to persist history (default: profswitch_history.json)
+	HistoryDB        string              `yaml:"history_db"`        // directory of the long-term store (empty = disabled)
+	RawRetention     int                 `yaml:"raw_retention_days"`
+	HourlyRetention  int                 `yaml:"hourly_retention_days"`
+	DailyRetention   int                 `yaml:"daily_retention_days"` // 0 = keep daily aggregates forever
+	SwitchThreshold  float64             `yaml:"switch_threshold"`     // minimum gain in percent before leaving the current coin
+	MinDwell         int                 `yaml:"min_dwell"`            // minimum seconds on a coin before switching away
+	Smoothing        int                 `yaml:"smoothing"`            // rank coins on the mean of the last N samples (default 1 = latest only)
+	PayoutMaxDays    float64             `yaml:"payout_max_days"`      // avoid coins that need longer than this to reach payout (0 = off)
+	PayoutFinishDays float64             `yaml:"payout_finish_days"`   // prefer a coin that reaches payout within this many days...
+	PayoutMargin     float64             `yaml:"payout_margin"`        // ...if it earns at most this many percent less than the best coin
+	AnomalyRatio     float64             `yaml:"anomaly_ratio"`        // quarantine revenue this many times above/below the recent median (0 = off)
+	AnomalyZScore    float64             `yaml:"anomaly_zscore"`       // quarantine revenue this many standard deviations from the recent mean (0 = off)
+	AnomalyWindow    int                 `yaml:"anomaly_window"`       // recent snapshots to compare against (default 12)
+	AnomalyConfirm   int                 `yaml:"anomaly_confirm"`      // cycles an outlying level must hold before it is accepted (default 3)
+	LastKnownMaxAge  string              `yaml:"last_known_max_age"`   // keep ranking a failing coin on its last values up to this old (default 1h, 0 = off)
+	ChartUnits       string              `yaml:"chart_units"`          // fiat (default) or btc (BTC/MH/day)
+	ChartWindow      string              `yaml:"chart_window"`         // time span plotted, e.g. 6h, 24h, 7d (empty = all of history_file, bucketed to fit)
+	ChartWidth       int                 `yaml:"chart_width"`          // plot columns (0 = fit the terminal)
+	ChartHeight      int                 `yaml:"chart_height"`         // plot rows (default 15)
+	ChartImage       string              `yaml:"chart_image"`          // write the chart to this .svg or .png file after each cycle
+	ChartImageWidth  int                 `yaml:"chart_image_width"`    // image size in pixels (default 960x480)
+	ChartImageHeight int                 `yaml:"chart_image_height"`
+	PriceSources     []PriceSourceConfig `yaml:"price_sources"`    // extra coin price sources, combined with Kryptex by median
+	PriceMaxSpread   float64             `yaml:"price_max_spread"` // skip a coin whose sources differ by more than this percent (default 10, negative = off)
+	Coins            []CoinConfig        `yaml:"coins"`
+
+	httpClient   *http.Client    // built from CABundle/HTTPProxy, installed by the caller
+	chartWindow  time.Duration   // parsed ChartWindow
+	lastKnownAge time.Duration   // parsed LastKnownMaxAge
+	priceSources []PriceProvider // built from PriceSources
 }
 
-func loadConfig(path string) (*Config, error) {
+// placeholderProfileID is the value shipped in config.example.yaml.
+const placeholderProfileID = "REPLACE_WITH_PROFILE_ID"
+
+// loadConfig reads, expands and defaults the config at path. In strict mode
+// unknown keys are rejected and validateConfig must pass.
+func loadConfig(path string, strict bool) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
+	data, err = expandEnv(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(strict)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
+	if err := applyEnvOverrides(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.ProxyAPIKeyFile != "" {
+		if cfg.ProxyAPIKey != "" {
+			return nil, fmt.Errorf("proxy_api_key and proxy_api_key_file are mutually exclusive")
+		}
+		key, err := os.ReadFile(cfg.ProxyAPIKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read proxy_api_key_file: %w", err)
+		}
+		cfg.ProxyAPIKey = strings.TrimSpace(string(key))
+	}
+	registerSecret(cfg.ProxyAPIKey)
 	// Defaults
-	cfg.ProxyBaseURL = "https://api.ultimate-proxy.com"
+	if cfg.ProxyBaseURL == "" {
+		cfg.ProxyBaseURL = "https://api.ultimate-proxy.com"
+	}
+	cfg.ProxyBaseURL = strings.TrimRight(cfg.ProxyBaseURL, "/")
 	if cfg.KryptexBaseURL == "" {
 		cfg.KryptexBaseURL = "https://pool.kryptex.com/api/v1"
 	}
+	cfg.KryptexBaseURL = strings.TrimRight(cfg.KryptexBaseURL, "/")
+	cfg.httpClient, err = newHTTPClient(cfg.CABundle, cfg.HTTPProxy)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HTTPCache == nil {
+		cfg.HTTPCache = defaultCacheRules
+	}
+	rules, err := parseCacheRules(cfg.HTTPCache)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HTTPMaxStale == "" {
+		cfg.HTTPMaxStale = "1h"
+	}
+	maxStale, err := parseDuration(cfg.HTTPMaxStale)
+	if err != nil || maxStale < 0 {
+		return nil, fmt.Errorf("invalid http_max_stale %q (use e.g. 30m, 1h or 0 to never serve stale data)", cfg.HTTPMaxStale)
+	}
+	if cfg.HTTPRateLimit < 0 {
+		return nil, fmt.Errorf("http_rate_limit must not be negative")
+	}
+	if cfg.HTTPCacheDir != "" {
+		if err := os.MkdirAll(cfg.HTTPCacheDir, 0700); err != nil {
+			return nil, fmt.Errorf("http_cache_dir: %w", err)
+		}
+	}
+	cfg.httpClient.Transport = newCachingTransport(cfg.httpClient.Transport, rules, maxStale, cfg.HTTPCacheDir, cfg.HTTPRateLimit)
 	if cfg.FiatCurrency == "" {
 		cfg.FiatCurrency = "USD"
 	}
@@ -49,21 +164,362 @@ func loadConfig(path string) (*Config, error) {
 	if cfg.DefaultHashrate <= 0 {
 		cfg.DefaultHashrate = 1000
 	}
+	if cfg.HashrateRange == "" {
+		cfg.HashrateRange = "1h"
+	}
+	if _, ok := hashrateRanges[cfg.HashrateRange]; !ok {
+		return nil, fmt.Errorf("invalid hashrate_range %q (use 1h, 6h, 24h or 7d)", cfg.HashrateRange)
+	}
+	cfg.HashrateStat = strings.ToLower(cfg.HashrateStat)
+	switch cfg.HashrateStat {
+	case "":
+		cfg.HashrateStat = hashrateAvg
+	case hashrateAvg, hashratePeak, hashrateCurrent:
+	default:
+		return nil, fmt.Errorf("hashrate_stat must be avg, peak or current, got %q", cfg.HashrateStat)
+	}
+	if cfg.HashrateFallback == nil {
+		cfg.HashrateFallback = []string{hashrateHistory, hashrateDefault}
+	}
+	seen := make(map[string]bool, len(cfg.HashrateFallback))
+	for i, src := range cfg.HashrateFallback {
+		src = strings.ToLower(src)
+		if src != hashrateHistory && src != hashrateDefault {
+			return nil, fmt.Errorf("hashrate_fallback: unknown source %q (use history or default)", src)
+		}
+		if seen[src] {
+			return nil, fmt.Errorf("hashrate_fallback: %s is listed twice", src)
+		}
+		seen[src] = true
+		cfg.HashrateFallback[i] = src
+	}
 	if cfg.HistoryFile == "" {
 		cfg.HistoryFile = "profswitch_history.json"
 	}
+	if cfg.RawRetention <= 0 {
+		cfg.RawRetention = 7
+	}
+	if cfg.HourlyRetention <= 0 {
+		cfg.HourlyRetention = 90
+	}
+	if cfg.HourlyRetention <= cfg.RawRetention || (cfg.DailyRetention > 0 && cfg.DailyRetention <= cfg.HourlyRetention) {
+		return nil, fmt.Errorf("retentions must increase from raw to hourly to daily, got raw_retention_days %d, hourly_retention_days %d, daily_retention_days %d",
+			cfg.RawRetention, cfg.HourlyRetention, cfg.DailyRetention)
+	}
+	if cfg.SwitchThreshold < 0 || cfg.MinDwell < 0 || cfg.Smoothing < 0 {
+		return nil, fmt.Errorf("switch_threshold, min_dwell and smoothing must not be negative")
+	}
+	if cfg.Smoothing == 0 {
+		cfg.Smoothing = 1 // 0 and 1 both rank on the latest sample
+	}
+	if cfg.PayoutMaxDays < 0 || cfg.PayoutFinishDays < 0 || cfg.PayoutMargin < 0 {
+		return nil, fmt.Errorf("payout_max_days, payout_finish_days and payout_margin must not be negative")
+	}
+	if cfg.AnomalyRatio < 0 || cfg.AnomalyZScore < 0 || cfg.AnomalyWindow < 0 || cfg.AnomalyConfirm < 0 {
+		return nil, fmt.Errorf("anomaly_ratio, anomaly_zscore, anomaly_window and anomaly_confirm must not be negative")
+	}
+	if cfg.AnomalyRatio > 0 && cfg.AnomalyRatio <= 1 {
+		return nil, fmt.Errorf("anomaly_ratio must be greater than 1, got %g", cfg.AnomalyRatio)
+	}
+	if cfg.AnomalyWindow == 0 {
+		cfg.AnomalyWindow = 12
+	}
+	if cfg.AnomalyConfirm == 0 {
+		cfg.AnomalyConfirm = 3
+	}
+	if cfg.LastKnownMaxAge == "" {
+		cfg.LastKnownMaxAge = "1h"
+	}
+	if cfg.lastKnownAge, err = parseDuration(cfg.LastKnownMaxAge); err != nil || cfg.lastKnownAge < 0 {
+		return nil, fmt.Errorf("invalid last_known_max_age %q (use e.g. 30m, 1h or 0 to drop failing coins)", cfg.LastKnownMaxAge)
+	}
+	cfg.ChartUnits = strings.ToLower(cfg.ChartUnits)
+	switch cfg.ChartUnits {
+	case "":
+		cfg.ChartUnits = "fiat"
+	case "fiat", "btc":
+	default:
+		return nil, fmt.Errorf("chart_units must be fiat or btc, got %q", cfg.ChartUnits)
+	}
+	if cfg.ChartWindow != "" {
+		if cfg.chartWindow, err = parseDuration(cfg.ChartWindow); err != nil || cfg.chartWindow <= 0 {
+			return nil, fmt.Errorf("invalid chart_window %q (use e.g. 6h, 24h or 7d)", cfg.ChartWindow)
+		}
+	}
+	if cfg.ChartHeight == 0 {
+		cfg.ChartHeight = 15
+	}
+	if cfg.ChartWidth < 0 || cfg.ChartHeight < 3 {
+		return nil, fmt.Errorf("chart_width must not be negative and chart_height must be at least 3")
+	}
+	if ext := strings.ToLower(filepath.Ext(cfg.ChartImage)); cfg.ChartImage != "" && ext != ".svg" && ext != ".png" {
+		return nil, fmt.Errorf("chart_image must end in .svg or .png, got %q", cfg.ChartImage)
+	}
+	if cfg.ChartImageWidth == 0 {
+		cfg.ChartImageWidth = defaultImageWidth
+	}
+	if cfg.ChartImageHeight == 0 {
+		cfg.ChartImageHeight = defaultImageHeight
+	}
+	if cfg.ChartImageWidth < 200 || cfg.ChartImageHeight < 150 {
+		return nil, fmt.Errorf("chart_image_width and chart_image_height must be at least 200x150")
+	}
+	if cfg.PriceMaxSpread == 0 {
+		cfg.PriceMaxSpread = 10
+	}
+	seenSource := map[string]bool{"kryptex": true}
+	for i, sc := range cfg.PriceSources {
+		if sc.Name == "" {
+			return nil, fmt.Errorf("price_sources[%d]: name is empty", i)
+		}
+		if seenSource[sc.Name] {
+			return nil, fmt.Errorf("price_sources[%d]: duplicate name %q", i, sc.Name)
+		}
+		seenSource[sc.Name] = true
+		p, err := newPriceProvider(sc)
+		if err != nil {
+			return nil, err
+		}
+		cfg.priceSources = append(cfg.priceSources, p)
+	}
 	if cfg.ProxyAlgorithm == "" {
 		return nil, fmt.Errorf("proxy_algorithm is required (e.g. kawpow, randomx, verushash)")
 	}
 	cfg.ProxyAlgorithm = strings.ToLower(cfg.ProxyAlgorithm)
 	for i := range cfg.Coins {
-		cfg.Coins[i].Ticker = strings.ToUpper(cfg.Coins[i].Ticker)
-		if cfg.Coins[i].RevenueTicker != "" {
-			cfg.Coins[i].RevenueTicker = strings.ToUpper(cfg.Coins[i].RevenueTicker)
+		c := &cfg.Coins[i]
+		c.Ticker = strings.ToUpper(c.Ticker)
+		if c.RevenueTicker != "" {
+			c.RevenueTicker = strings.ToUpper(c.RevenueTicker)
+		}
+		if c.PayoutThreshold < 0 || c.UnpaidBalance < 0 {
+			return nil, fmt.Errorf("coins[%d] (%s): payout_threshold and unpaid_balance must not be negative", i, c.Ticker)
+		}
+		if c.BalanceURL != "" && c.BalanceField == "" {
+			return nil, fmt.Errorf("coins[%d] (%s): balance_url needs balance_field", i, c.Ticker)
 		}
 	}
 	if len(cfg.Coins) == 0 {
 		return nil, fmt.Errorf("no coins configured")
 	}
+	if strict {
+		if err := validateConfig(&cfg); err != nil {
+			return nil, err
+		}
+	}
 	return &cfg, nil
 }
+
+// coin returns the configured coin with the given ticker.
+func (c *Config) coin(ticker string) (*CoinConfig, bool) {
+	for i := range c.Coins {
+		if c.Coins[i].Ticker == ticker {
+			return &c.Coins[i], true
+		}
+	}
+	return nil, false
+}
+
+// chartOptions returns the chart settings of the config.
+func (c *Config) chartOptions() chartOptions {
+	return chartOptions{
+		BTC:    c.ChartUnits == "btc",
+		Window: c.chartWindow,
+		Width:  c.ChartWidth,
+		Height: c.ChartHeight,
+	}
+}
+
+// validateConfig reports every problem in an already-defaulted config, one per
+// line, instead of stopping at the first.
+func validateConfig(cfg *Config) error {
+	var errs []error
+	if cfg.ProxyAPIKey == "" {
+		errs = append(errs, fmt.Errorf("proxy_api_key is empty"))
+	} else if strings.Contains(cfg.ProxyAPIKey, "xxxxxxxx") {
+		errs = append(errs, fmt.Errorf("proxy_api_key is still the example placeholder"))
+	}
+	seenTicker := make(map[string]int)
+	seenProfile := make(map[string]int)
+	for i, c := range cfg.Coins {
+		name := fmt.Sprintf("coins[%d]", i)
+		if c.Ticker == "" {
+			errs = append(errs, fmt.Errorf("%s: ticker is empty", name))
+		} else {
+			name = fmt.Sprintf("coins[%d] (%s)", i, c.Ticker)
+			if j, dup := seenTicker[c.Ticker]; dup {
+				errs = append(errs, fmt.Errorf("%s: duplicate ticker, already defined in coins[%d]", name, j))
+			} else {
+				seenTicker[c.Ticker] = i
+			}
+		}
+		if c.Wallet != "" && cfg.KryptexStatsURL == "" {
+			errs = append(errs, fmt.Errorf("%s: wallet is set but kryptex_stats_url is empty", name))
+		}
+		switch {
+		case c.ProfileID == "":
+			errs = append(errs, fmt.Errorf("%s: profile_id is empty", name))
+		case c.ProfileID == placeholderProfileID:
+			errs = append(errs, fmt.Errorf("%s: profile_id is still %s — copy the ID from https://ultimate-proxy.com/profiles", name, placeholderProfileID))
+		default:
+			if j, dup := seenProfile[c.ProfileID]; dup {
+				errs = append(errs, fmt.Errorf("%s: profile_id %s is already used by coins[%d]", name, c.ProfileID, j))
+			} else {
+				seenProfile[c.ProfileID] = i
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} references in YAML values with the value of the
+// environment variable VAR. Comments are left alone. Unset variables are an
+// error rather than an empty string so a missing secret fails loudly.
+func expandEnv(data []byte) ([]byte, error) {
+	if !envRefPattern.Match(data) {
+		return data, nil
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	var missing []string
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.ScalarNode {
+			v := envRefPattern.ReplaceAllStringFunc(n.Value, func(ref string) string {
+				name := envRefPattern.FindStringSubmatch(ref)[1]
+				v, ok := os.LookupEnv(name)
+				if !ok {
+					missing = append(missing, name)
+					return ref
+				}
+				return v
+			})
+			// "${IV}" resolved as a string: drop that tag so "120" decodes into an int field
+			if v != n.Value && n.Style&yaml.TaggedStyle == 0 {
+				n.Tag = ""
+			}
+			n.Value = v
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	walk(&root)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("unset environment variable(s): %s", strings.Join(missing, ", "))
+	}
+	return yaml.Marshal(&root)
+}
+
+// applyEnvOverrides sets every tagged Config field from PROFSWITCH_<YAML_KEY>
+// when that variable is set. Coins are given as a comma-separated list of
+// TICKER[:REVENUE_TICKER]=PROFILE_ID entries in PROFSWITCH_COINS.
+func applyEnvOverrides(cfg *Config) error {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		name := envPrefix + strings.ToUpper(key)
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(raw)
+		case reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("%s: invalid integer %q", name, raw)
+			}
+			f.SetInt(int64(n))
+		case reflect.Float64:
+			x, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return fmt.Errorf("%s: invalid number %q", name, raw)
+			}
+			f.SetFloat(x)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("%s: invalid boolean %q", name, raw)
+			}
+			f.SetBool(b)
+		case reflect.Slice:
+			switch {
+			case key == "coins":
+				coins, err := parseCoinsEnv(raw)
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				cfg.Coins = coins
+			case key == "price_sources":
+				// YAML flow or JSON, e.g. [{name: binance, type: ticker, url: "..."}]
+				dec := yaml.NewDecoder(strings.NewReader(raw))
+				dec.KnownFields(true)
+				var sources []PriceSourceConfig
+				if err := dec.Decode(&sources); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				cfg.PriceSources = sources
+			case f.Type().Elem().Kind() == reflect.String:
+				// comma-separated, e.g. PROFSWITCH_HASHRATE_FALLBACK=history,default
+				var list []string
+				for _, item := range strings.Split(raw, ",") {
+					if item = strings.TrimSpace(item); item != "" {
+						list = append(list, item)
+					}
+				}
+				f.Set(reflect.ValueOf(list))
+			default:
+				return fmt.Errorf("%s: unsupported override", name)
+			}
+		case reflect.Map:
+			// comma-separated key=value pairs, e.g. PROFSWITCH_HTTP_CACHE=/rates=1m,/daily-revenue/=10m
+			m := make(map[string]string)
+			for _, entry := range strings.Split(raw, ",") {
+				if entry = strings.TrimSpace(entry); entry == "" {
+					continue
+				}
+				i := strings.LastIndex(entry, "=")
+				if i <= 0 {
+					return fmt.Errorf("%s: invalid entry %q (want KEY=VALUE)", name, entry)
+				}
+				m[strings.TrimSpace(entry[:i])] = strings.TrimSpace(entry[i+1:])
+			}
+			f.Set(reflect.ValueOf(m))
+		default:
+			return fmt.Errorf("%s: unsupported override", name)
+		}
+	}
+	return nil
+}
+
+func parseCoinsEnv(raw string) ([]CoinConfig, error) {
+	var coins []CoinConfig
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tickers, profileID, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid coin entry %q (want TICKER[:REVENUE_TICKER]=PROFILE_ID)", entry)
+		}
+		ticker, revTicker, _ := strings.Cut(tickers, ":")
+		coins = append(coins, CoinConfig{
+			Ticker:        strings.TrimSpace(ticker),
+			RevenueTicker: strings.TrimSpace(revTicker),
+			ProfileID:     strings.TrimSpace(profileID),
+		})
+	}
+	return coins, nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file into a temporary directory and returns its path.
+func writeConfig(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigExpandsEnv(t *testing.T) {
+	t.Setenv("PS_TEST_KEY", "up_k_env_key")
+	t.Setenv("PS_TEST_INTERVAL", "120")
+	t.Setenv("PS_TEST_THRESHOLD", "2.5")
+	path := writeConfig(t, `
+# proxy_api_key: "${PS_TEST_UNSET}" (comments are not expanded)
+proxy_api_key: "${PS_TEST_KEY}"
+proxy_algorithm: randomx
+interval: ${PS_TEST_INTERVAL}
+switch_threshold: ${PS_TEST_THRESHOLD}
+coins:
+  - ticker: XMR
+    profile_id: p-xmr
+`)
+	cfg, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ProxyAPIKey != "up_k_env_key" || cfg.Interval != 120 || cfg.SwitchThreshold != 2.5 {
+		t.Errorf("got key %q, interval %d, threshold %g; want up_k_env_key, 120, 2.5", cfg.ProxyAPIKey, cfg.Interval, cfg.SwitchThreshold)
+	}
+}
+
+func TestLoadConfigUnsetEnv(t *testing.T) {
+	path := writeConfig(t, `
+proxy_api_key: "${PS_TEST_UNSET}"
+proxy_algorithm: randomx
+coins:
+  - ticker: XMR
+    profile_id: p-xmr
+`)
+	_, err := loadConfig(path, false)
+	if err == nil || !strings.Contains(err.Error(), "PS_TEST_UNSET") {
+		t.Fatalf("got error %v, want one naming PS_TEST_UNSET", err)
+	}
+}
+
+func TestLoadConfigRetentionOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention string
+		ok        bool
+	}{
+		{"defaults", "", true},
+		{"daily kept forever", "raw_retention_days: 3\nhourly_retention_days: 30\ndaily_retention_days: 0\n", true},
+		{"increasing", "raw_retention_days: 3\nhourly_retention_days: 30\ndaily_retention_days: 365\n", true},
+		{"hourly shorter than raw", "raw_retention_days: 30\nhourly_retention_days: 3\n", false},
+		{"hourly equal to raw", "raw_retention_days: 30\nhourly_retention_days: 30\n", false},
+		{"daily shorter than hourly", "daily_retention_days: 30\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, `
+proxy_api_key: up_k_retention_key
+proxy_algorithm: randomx
+coins:
+  - ticker: XMR
+    profile_id: p-xmr
+`+tt.retention)
+			_, err := loadConfig(path, true)
+			if tt.ok != (err == nil) {
+				t.Errorf("err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLoadConfigPriceMaxSpread(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  float64
+	}{{"", 10}, {"price_max_spread: 0\n", 10}, {"price_max_spread: 25\n", 25}, {"price_max_spread: -1\n", -1}} {
+		path := writeConfig(t, `
+proxy_api_key: up_k_spread_key
+proxy_algorithm: randomx
+coins:
+  - ticker: XMR
+    profile_id: p-xmr
+`+tt.value)
+		cfg, err := loadConfig(path, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.PriceMaxSpread != tt.want {
+			t.Errorf("%q: price_max_spread = %g, want %g", tt.value, cfg.PriceMaxSpread, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigListOverride(t *testing.T) {
+	t.Setenv(envPrefix+"HASHRATE_FALLBACK", "default, history")
+	path := writeConfig(t, `
+proxy_api_key: up_k_override_key
+proxy_algorithm: randomx
+hashrate_fallback: [history]
+coins:
+  - ticker: XMR
+    profile_id: p-xmr
+`)
+	cfg, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(cfg.HashrateFallback, ","); got != "default,history" {
+		t.Errorf("hashrate_fallback = %s, want default,history", got)
+	}
+}
+
+func TestLoadConfigPriceSourcesOverride(t *testing.T) {
+	t.Setenv(envPrefix+"PRICE_SOURCES", `[{name: cg, type: coingecko, symbols: {XMR: monero}}]`)
+	path := writeConfig(t, `
+proxy_api_key: up_k_override_key
+proxy_algorithm: randomx
+coins:
+  - ticker: XMR
+    profile_id: p-xmr
+`)
+	cfg, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.PriceSources) != 1 || cfg.PriceSources[0].Name != "cg" || cfg.PriceSources[0].Symbols["XMR"] != "monero" || len(cfg.priceSources) != 1 {
+		t.Errorf("price_sources = %+v", cfg.PriceSources)
+	}
+
+	t.Setenv(envPrefix+"PRICE_SOURCES", `[{name: cg, kind: coingecko}]`)
+	if _, err := loadConfig(path, true); err == nil {
+		t.Error("expected an error for an unknown price source field")
+	}
+}
+
+func TestLoadConfigMapOverride(t *testing.T) {
+	path := writeConfig(t, `
+proxy_api_key: up_k_override_key
+proxy_algorithm: randomx
+coins:
+  - ticker: XMR
+    profile_id: p-xmr
+`)
+	t.Setenv(envPrefix+"HTTP_CACHE", "/rates=2m, /daily-revenue/=10m")
+	cfg, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.HTTPCache) != 2 || cfg.HTTPCache["/rates"] != "2m" || cfg.HTTPCache["/daily-revenue/"] != "10m" {
+		t.Errorf("http_cache = %v", cfg.HTTPCache)
+	}
+
+	t.Setenv(envPrefix+"HTTP_CACHE", "/rates")
+	if _, err := loadConfig(path, true); err == nil {
+		t.Error("expected an error for an entry without a time to live")
+	}
+}
+
+func TestLoadConfigWalletNeedsStatsURL(t *testing.T) {
+	base := `
+proxy_api_key: up_k_wallet_key
+proxy_algorithm: randomx
+coins:
+  - ticker: XMR
+    profile_id: p-xmr
+    wallet: w-xmr
+`
+	if _, err := loadConfig(writeConfig(t, base), true); err == nil || !strings.Contains(err.Error(), "kryptex_stats_url") {
+		t.Errorf("err = %v, want one about kryptex_stats_url", err)
+	}
+	cfg, err := loadConfig(writeConfig(t, base+"kryptex_stats_url: \"http://stats.example/{coin}/{wallet}\"\n"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.KryptexStatsURL != "http://stats.example/{coin}/{wallet}" {
+		t.Errorf("kryptex_stats_url = %q", cfg.KryptexStatsURL)
+	}
+}
+
+func TestReloadKeepsUnchangedSettings(t *testing.T) {
+	base := `
+proxy_api_key: up_k_reload_key
+proxy_algorithm: randomx
+coins:
+  - ticker: XMR
+    profile_id: p-xmr
+`
+	load := func(extra string) *Config {
+		t.Helper()
+		cfg, err := loadConfig(writeConfig(t, base+extra), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+	cur := load("switch_threshold: 5\nhistory_db: db\n")
+	for _, tt := range []struct {
+		extra       string
+		http, store bool
+	}{
+		{"switch_threshold: 8\nhistory_db: db\n", true, true},
+		{"history_db: other\n", true, false},
+		{"history_db: db\nraw_retention_days: 2\n", true, false},
+		{"history_db: db\nhttp_rate_limit: 2\n", false, true},
+		{"history_db: db\nhttp_cache: {/rates: 1m}\n", false, true},
+		{"history_db: db\nhttp_proxy: \"http://proxy.example:3128\"\n", false, true},
+	} {
+		next := load(tt.extra)
+		if got := sameHTTPSettings(next, cur); got != tt.http {
+			t.Errorf("%q: same HTTP settings = %v, want %v", tt.extra, got, tt.http)
+		}
+		if got := sameStoreSettings(next, cur); got != tt.store {
+			t.Errorf("%q: same store settings = %v, want %v", tt.extra, got, tt.store)
+		}
+	}
+}
+
+func TestInitConfigKeyReference(t *testing.T) {
+	t.Setenv(envPrefix+"PROXY_API_KEY", "up_k_init_key")
+	coins := []CoinConfig{{Ticker: "XMR", ProfileID: "p-xmr"}}
+	data := renderInitConfig("${"+envPrefix+"PROXY_API_KEY}", "", "https://api.ultimate-proxy.com", "https://pool.kryptex.com/api/v1", "randomx", "USD", 300, 1000, coins)
+	if strings.Contains(string(data), "up_k_init_key") {
+		t.Fatalf("config contains the key itself:\n%s", data)
+	}
+	cfg, err := loadConfig(writeConfig(t, string(data)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ProxyAPIKey != "up_k_init_key" {
+		t.Errorf("proxy_api_key = %q, want the value of the variable", cfg.ProxyAPIKey)
+	}
+
+	t.Setenv(envPrefix+"PROXY_API_KEY", "")
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("up_k_file_key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data = renderInitConfig("", keyFile, "https://api.ultimate-proxy.com", "https://pool.kryptex.com/api/v1", "randomx", "USD", 300, 1000, coins)
+	if cfg, err = loadConfig(writeConfig(t, string(data)), true); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ProxyAPIKey != "up_k_file_key" {
+		t.Errorf("proxy_api_key = %q, want the contents of proxy_api_key_file", cfg.ProxyAPIKey)
+	}
+}
+
+func TestLoadConfigHashrateRange(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		ok    bool
+	}{{"", true}, {"6h", true}, {"7d", true}, {"15m", false}, {"90m", false}, {"1 h", false}} {
+		path := writeConfig(t, `
+proxy_api_key: up_k_range_key
+proxy_algorithm: randomx
+coins:
+  - ticker: XMR
+    profile_id: p-xmr
+hashrate_range: "`+tt.value+`"
+`)
+		if _, err := loadConfig(path, true); tt.ok != (err == nil) {
+			t.Errorf("hashrate_range %q: err = %v, want ok %v", tt.value, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportRow is one coin at one point in time, flattened for spreadsheets and
+// notebooks.
+type exportRow struct {
+	Time     time.Time `json:"timestamp"`
+	Ticker   string    `json:"ticker"`
+	Fiat     float64   `json:"fiat_revenue"`
+	BTCPerMH float64   `json:"btc_per_mh_day"`
+	Mining   string    `json:"mining"`
+	Switched bool      `json:"switched"`
+	PriceUSD float64   `json:"price_usd,omitempty"`
+	CoinRev  float64   `json:"coin_revenue,omitempty"`
+	Hashrate float64   `json:"hashrate,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	HRSource string    `json:"hashrate_source,omitempty"`
+}
+
+// cmdExport implements the `export` subcommand: persisted snapshots as CSV or
+// NDJSON, one row per coin per snapshot.
+func cmdExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	format := fs.String("format", "csv", "Output format: csv or ndjson")
+	since := fs.String("since", "", "Start of the range: a duration back from now (24h, 30d) or a date (default: everything)")
+	until := fs.String("until", "", "End of the range, same formats as -since (default: now)")
+	coinsFlag := fs.String("coins", "", "Comma-separated tickers to include (default: all)")
+	output := fs.String("o", "", "Write to this file instead of stdout")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+	if *format != "csv" && *format != "ndjson" {
+		fmt.Fprintf(os.Stderr, "unknown format %q (use csv or ndjson)\n", *format)
+		return 1
+	}
+	cfg, err := loadConfig(*configPath, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	now := time.Now()
+	var from time.Time
+	to := now.Add(time.Second)
+	if *since != "" {
+		if from, err = parseTimeArg(*since, now); err != nil {
+			fmt.Fprintf(os.Stderr, "-since: %v\n", err)
+			return 1
+		}
+	}
+	if *until != "" {
+		if to, err = parseTimeArg(*until, now); err != nil {
+			fmt.Fprintf(os.Stderr, "-until: %v\n", err)
+			return 1
+		}
+	}
+	var coins map[string]bool
+	if *coinsFlag != "" {
+		coins = make(map[string]bool)
+		for _, t := range strings.Split(*coinsFlag, ",") {
+			coins[strings.ToUpper(strings.TrimSpace(t))] = true
+		}
+	}
+
+	snaps, err := loadSnapshots(cfg, from, to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if *output != "" {
+		if f, err = os.Create(*output); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	if *format == "csv" {
+		err = writeCSV(bw, exportRows(snaps, coins))
+	} else {
+		err = writeNDJSON(bw, exportRows(snaps, coins))
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	// A failed close can mean the data never reached the disk
+	if f != nil {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// exportRows flattens snapshots into rows sorted by time then ticker. A nil
+// coins filter keeps every ticker.
+func exportRows(snaps []Snapshot, coins map[string]bool) []exportRow {
+	var rows []exportRow
+	for _, s := range snaps {
+		tickers := make([]string, 0, len(s.Coins))
+		for t := range s.Coins {
+			if coins == nil || coins[t] {
+				tickers = append(tickers, t)
+			}
+		}
+		sort.Strings(tickers)
+		for _, t := range tickers {
+			rows = append(rows, exportRow{
+				Time:     s.Time,
+				Ticker:   t,
+				Fiat:     s.Coins[t],
+				BTCPerMH: s.CoinsBTC[t],
+				Mining:   s.Mining,
+				Switched: s.Switched,
+				PriceUSD: s.Prices[t],
+				CoinRev:  s.CoinRevenue[t],
+				Hashrate: s.Hashrate,
+				Reason:   s.Reason,
+				HRSource: s.HashrateSource,
+			})
+		}
+	}
+	return rows
+}
+
+func writeCSV(w io.Writer, rows []exportRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"timestamp", "ticker", "fiat_revenue", "btc_per_mh_day", "mining", "switched",
+		"price_usd", "coin_revenue", "hashrate", "reason", "hashrate_source"})
+	for _, r := range rows {
+		cw.Write([]string{
+			r.Time.Format(time.RFC3339),
+			r.Ticker,
+			strconv.FormatFloat(r.Fiat, 'f', -1, 64),
+			strconv.FormatFloat(r.BTCPerMH, 'f', -1, 64),
+			r.Mining,
+			strconv.FormatBool(r.Switched),
+			strconv.FormatFloat(r.PriceUSD, 'f', -1, 64),
+			strconv.FormatFloat(r.CoinRev, 'f', -1, 64),
+			strconv.FormatFloat(r.Hashrate, 'f', -1, 64),
+			r.Reason,
+			r.HRSource,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeNDJSON(w io.Writer, rows []exportRow) error {
+	enc := json.NewEncoder(w)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExportGolden(t *testing.T) {
+	dir := t.TempDir()
+	histPath := filepath.Join(dir, "history.json")
+	h := NewHistory(10)
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		h.Add(Snapshot{
+			Time:           t0.Add(time.Duration(i) * time.Hour),
+			Coins:          map[string]float64{"XMR": 1.5 + float64(i)/10, "SAL": 1.25, "XTM": 0.75},
+			CoinsBTC:       map[string]float64{"XMR": 0.00002, "SAL": 0.000015, "XTM": 0.00001},
+			Prices:         map[string]float64{"XMR": 150, "SAL": 0.04},
+			CoinRevenue:    map[string]float64{"XMR": 0.01, "SAL": 31.25},
+			Mining:         "XMR",
+			Switched:       i == 2,
+			Hashrate:       40000,
+			HashrateSource: "live",
+			Reason:         reasonBest,
+		})
+	}
+	if err := h.Save(histPath); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	config := fmt.Sprintf(`
+proxy_api_key: up_k_export_key
+proxy_algorithm: randomx
+history_file: %q
+coins:
+  - ticker: XMR
+    profile_id: p-xmr
+`, histPath)
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The middle two snapshots (until is exclusive), without XTM
+	for _, format := range []string{"csv", "ndjson"} {
+		t.Run(format, func(t *testing.T) {
+			out := filepath.Join(dir, "export."+format)
+			code := cmdExport([]string{"-c", configPath, "-format", format, "-o", out,
+				"-since", "2026-03-01T13:00:00Z", "-until", "2026-03-01T15:00:00Z", "-coins", "xmr, sal"})
+			if code != 0 {
+				t.Fatalf("exit code %d", code)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "export."+format+".golden", got)
+		})
+	}
+}
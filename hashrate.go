@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Hashrate stats selectable with hashrate_stat.
+const (
+	hashrateAvg     = "avg"
+	hashratePeak    = "peak"
+	hashrateCurrent = "current"
+)
+
+// Hashrate sources recorded in Snapshot.HashrateSource; the last two can be
+// listed in hashrate_fallback.
+const (
+	hashrateLive    = "live"    // from the Ultimate Proxy API this cycle
+	hashrateHistory = "history" // the last live value recorded in the history
+	hashrateDefault = "default" // default_hashrate
+)
+
+// currentHashrate returns the hashrate to estimate revenue for and where it
+// came from: the live value selected by hashrate_stat, or else the first
+// hashrate_fallback source that has one. It returns 0 when none has.
+func currentHashrate(cfg *Config, hist *History) (int, string) {
+	hr, err := liveHashrate(cfg)
+	if err == nil && hr >= 1 {
+		slog.Info("Live hashrate", "stat", cfg.HashrateStat, "range", cfg.HashrateRange, "hashrate", int(hr), "display", formatHashrate(hr))
+		return int(hr), hashrateLive
+	}
+	if err != nil {
+		slog.Warn("Failed to fetch hashrate", "err", err)
+	} else {
+		slog.Warn("No live hashrate data")
+	}
+	for _, src := range cfg.HashrateFallback {
+		switch src {
+		case hashrateHistory:
+			if s, ok := lastLiveHashrate(hist.All()); ok {
+				slog.Warn("Using the last live hashrate from history", "hashrate", int(s.Hashrate), "age", time.Since(s.Time).Round(time.Second).String())
+				return int(s.Hashrate), hashrateHistory
+			}
+		case hashrateDefault:
+			slog.Warn("Using default hashrate", "hashrate", cfg.DefaultHashrate)
+			return cfg.DefaultHashrate, hashrateDefault
+		}
+	}
+	return 0, ""
+}
+
+// liveHashrate reads the hashrate_stat value from the Ultimate Proxy API.
+func liveHashrate(cfg *Config) (float64, error) {
+	if cfg.HashrateStat == hashrateCurrent {
+		workers, err := fetchAllWorkers(cfg.ProxyBaseURL, cfg.ProxyAPIKey, cfg.ProxyAlgorithm)
+		if err != nil {
+			return 0, fmt.Errorf("fetch workers: %w", err)
+		}
+		var total float64
+		for _, w := range workers {
+			total += float64(w.Hashrate)
+		}
+		return total, nil
+	}
+	avg, peak, err := fetchHashrate(cfg.ProxyBaseURL, cfg.ProxyAPIKey, cfg.ProxyAlgorithm, cfg.HashrateRange)
+	if cfg.HashrateStat == hashratePeak {
+		return peak, err
+	}
+	return avg, err
+}
+
+// lastLiveHashrate returns the newest snapshot whose hashrate was live.
+func lastLiveHashrate(snaps []Snapshot) (Snapshot, bool) {
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if snaps[i].HashrateSource == hashrateLive && snaps[i].Hashrate >= 1 {
+			return snaps[i], true
+		}
+	}
+	return Snapshot{}, false
+}
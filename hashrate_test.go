@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCurrentHashrate(t *testing.T) {
+	var ranges []string
+	up := true
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/workers/hashrate", func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		ranges = append(ranges, r.URL.Query().Get("timeRange"))
+		writeJSON(w, HashrateResponse{Stats: &HashrateStats{AvgHashrate: 9000, PeakHashrate: 12000}})
+	})
+	mux.HandleFunc("GET /v1/workers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, WorkersResponse{Data: []Worker{{ID: "a", Hashrate: 4000}, {ID: "b", Hashrate: 6500}}, Pagination: Pagination{TotalPages: 1}})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	hist := NewHistory(10)
+	t0 := time.Now().Add(-time.Hour)
+	hist.Add(Snapshot{Time: t0, Hashrate: 7000, HashrateSource: hashrateLive})
+	hist.Add(Snapshot{Time: t0.Add(5 * time.Minute), Hashrate: 1000, HashrateSource: hashrateDefault})
+
+	tests := []struct {
+		name     string
+		stat     string
+		fallback []string
+		up       bool
+		want     int
+		wantSrc  string
+	}{
+		{"avg", hashrateAvg, nil, true, 9000, hashrateLive},
+		{"peak", hashratePeak, nil, true, 12000, hashrateLive},
+		{"current", hashrateCurrent, nil, true, 10500, hashrateLive},
+		{"history skips non-live snapshots", hashrateAvg, []string{hashrateHistory, hashrateDefault}, false, 7000, hashrateHistory},
+		{"default", hashrateAvg, []string{hashrateDefault, hashrateHistory}, false, 1000, hashrateDefault},
+		{"no fallback", hashrateAvg, []string{}, false, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up = tt.up
+			cfg := &Config{ProxyBaseURL: srv.URL, DefaultHashrate: 1000, HashrateRange: "6h", HashrateStat: tt.stat, HashrateFallback: tt.fallback}
+			got, src := currentHashrate(cfg, hist)
+			if got != tt.want || src != tt.wantSrc {
+				t.Errorf("got %d (%s), want %d (%s)", got, src, tt.want, tt.wantSrc)
+			}
+		})
+	}
+	for _, r := range ranges {
+		if r != "6h" {
+			t.Errorf("timeRange = %q, want 6h", r)
+		}
+	}
+}
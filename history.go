@@ -3,7 +3,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"sort"
 	"sync"
 	"time"
@@ -15,6 +17,40 @@ type Snapshot struct {
 	CoinsBTC map[string]float64 // ticker -> BTC/MH/Day
 	Mining   string             // ticker being mined at this point
 	Switched bool               // true if a switch happened at this snapshot
+	Samples  int                `json:",omitempty"` // raw samples aggregated into this point (0 = a single raw snapshot)
+
+	// Inputs and outcome of the decision (history version 2+).
+	Hashrate        float64            `json:",omitempty"` // H/s used for the revenue estimates
+	HashrateSource  string             `json:",omitempty"` // where Hashrate came from: live, history or default
+	Prices          map[string]float64 `json:",omitempty"` // ticker -> coin price in USD
+	CoinRevenue     map[string]float64 `json:",omitempty"` // ticker -> daily revenue in coin
+	FiatRate        float64            `json:",omitempty"` // USD per unit of the fiat currency, as reported by Kryptex
+	WorkersSwitched int                `json:",omitempty"` // workers reassigned at this snapshot
+	Reason          string             `json:",omitempty"` // why Mining was chosen (see reason* constants)
+
+	// Pool account stats, for coins with a wallet configured.
+	Earned       map[string]float64 `json:",omitempty"` // ticker -> total credited by the pool (paid + unpaid), in coin
+	PoolHashrate map[string]float64 `json:",omitempty"` // ticker -> hashrate reported by the pool, H/s
+}
+
+// Decision reasons recorded in Snapshot.Reason.
+const (
+	reasonBest      = "best"      // mining the most profitable coin
+	reasonThreshold = "threshold" // a better coin exists but the gain is below the switch threshold
+	reasonDwell     = "dwell"     // a better coin exists but the minimum dwell time has not elapsed
+	reasonPinned    = "pinned"    // the coin was pinned manually
+	reasonPaused    = "paused"    // a better coin exists but switching is paused
+	reasonPayout    = "payout"    // chosen for its payout threshold rather than the best revenue
+	reasonError     = "error"     // the switch to the best coin failed
+	reasonMissing   = "missing"   // the current coin has no data this cycle, so it is kept
+)
+
+// weight returns how many raw samples the snapshot stands for.
+func (s Snapshot) weight() int {
+	if s.Samples > 0 {
+		return s.Samples
+	}
+	return 1
 }
 
 type History struct {
@@ -36,6 +72,16 @@ func (h *History) Add(s Snapshot) {
 	}
 }
 
+// Resize changes the maximum number of snapshots kept, trimming the oldest if needed.
+func (h *History) Resize(maxLen int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxLen = maxLen
+	if len(h.snapshots) > h.maxLen {
+		h.snapshots = h.snapshots[len(h.snapshots)-h.maxLen:]
+	}
+}
+
 func (h *History) All() []Snapshot {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -63,7 +109,12 @@ type MinedAverage struct {
 func (h *History) Averages() ([]CoinAverage, MinedAverage) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	return averagesOf(h.snapshots)
+}
 
+// averagesOf computes per-coin averages over snaps. Aggregated snapshots
+// count once per raw sample they represent.
+func averagesOf(snaps []Snapshot) ([]CoinAverage, MinedAverage) {
 	type acc struct {
 		sumFiat float64
 		sumBTC  float64
@@ -72,29 +123,30 @@ func (h *History) Averages() ([]CoinAverage, MinedAverage) {
 	m := make(map[string]*acc)
 	var mined MinedAverage
 
-	for _, s := range h.snapshots {
+	for _, s := range snaps {
+		w := s.weight()
 		for t, v := range s.Coins {
 			a, ok := m[t]
 			if !ok {
 				a = &acc{}
 				m[t] = a
 			}
-			a.sumFiat += v
-			a.count++
+			a.sumFiat += v * float64(w)
+			a.count += w
 		}
 		for t, v := range s.CoinsBTC {
 			if a, ok := m[t]; ok {
-				a.sumBTC += v
+				a.sumBTC += v * float64(w)
 			}
 		}
 		// Track the coin that was actually being mined
 		if s.Mining != "" {
 			if fiat, ok := s.Coins[s.Mining]; ok {
-				mined.AvgFiat += fiat
-				mined.Count++
+				mined.AvgFiat += fiat * float64(w)
+				mined.Count += w
 			}
 			if btc, ok := s.CoinsBTC[s.Mining]; ok {
-				mined.AvgBTCMH += btc
+				mined.AvgBTCMH += btc * float64(w)
 			}
 		}
 	}
@@ -124,38 +176,212 @@ func (h *History) Averages() ([]CoinAverage, MinedAverage) {
 // Persistence
 // ---------------------------------------------------------------------------
 
+// historyVersion is the current on-disk format. Version 1 (no "version" key)
+// only has Coins, CoinsBTC, Mining and Switched per snapshot.
+const historyVersion = 2
+
 type persistedHistory struct {
+	Version   int        `json:"version,omitempty"`
 	Snapshots []Snapshot `json:"snapshots"`
 }
 
+// migrateHistory upgrades p in place to historyVersion.
+func migrateHistory(p *persistedHistory) error {
+	if p.Version == 0 {
+		p.Version = 1
+	}
+	if p.Version > historyVersion {
+		return fmt.Errorf("history version %d is newer than supported version %d", p.Version, historyVersion)
+	}
+	if p.Version == 1 {
+		// v1 -> v2: decision inputs were not recorded. Derive what we can.
+		for i := range p.Snapshots {
+			s := &p.Snapshots[i]
+			if s.Mining != "" && s.Reason == "" {
+				s.Reason = reasonBest
+			}
+		}
+		p.Version = 2
+	}
+	return nil
+}
+
+// Save writes the history atomically: data goes to a temp file that is fsynced
+// and renamed over path, and the previous file is kept as path.bak.
 func (h *History) Save(path string) error {
 	h.mu.Lock()
-	data, err := json.Marshal(persistedHistory{Snapshots: h.snapshots})
+	data, err := json.Marshal(persistedHistory{Version: historyVersion, Snapshots: h.snapshots})
 	h.mu.Unlock()
 	if err != nil {
 		return fmt.Errorf("marshal history: %w", err)
 	}
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := backupFile(path); err != nil {
+		return fmt.Errorf("backup history: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("write history: %w", err)
 	}
 	return nil
 }
 
-func (h *History) Load(path string) error {
-	data, err := os.ReadFile(path)
+// writeFileAtomic replaces path with data through a temp file and a single
+// rename, so that a crash or a concurrent reader sees either the old or the
+// new content, never a partial write.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
 	if err != nil {
-		return err // caller can check os.IsNotExist
+		return err
 	}
-	var p persistedHistory
-	if err := json.Unmarshal(data, &p); err != nil {
-		return fmt.Errorf("parse history: %w", err)
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+	// Persist the directory entry; not supported on every platform.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// backupFile replaces path.bak with the current content of path, if any, by
+// hard-linking it (or copying where links are unsupported) so that path stays
+// in place throughout.
+func backupFile(path string) error {
+	bak := path + ".bak"
+	tmp := bak + ".tmp"
+	os.Remove(tmp)
+	if err := os.Link(path, tmp); err != nil {
+		info, statErr := os.Stat(path)
+		if os.IsNotExist(statErr) {
+			return nil
+		} else if statErr != nil {
+			return statErr
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(tmp, data, info.Mode().Perm()); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, bak)
+}
+
+// Load reads the history from path. If path is missing or corrupt, it falls
+// back to path.bak and to whatever complete snapshots can be salvaged from the
+// damaged file, keeping whichever is most recent.
+func (h *History) Load(path string) error {
+	snaps, err := readHistoryFile(path)
+	if err != nil {
+		primaryErr := err
+		backup, bakErr := readHistoryFile(path + ".bak")
+		salvaged := salvageHistoryFile(path)
+		switch {
+		case bakErr != nil && len(salvaged) == 0:
+			return primaryErr // caller can check os.IsNotExist
+		case bakErr != nil || lastTime(salvaged).After(lastTime(backup)):
+			snaps = salvaged
+			slog.Warn("History unreadable, salvaged snapshots", "path", path, "err", primaryErr, "snapshots", len(snaps))
+		default:
+			snaps = backup
+			if !os.IsNotExist(primaryErr) {
+				slog.Warn("History unreadable, restored from backup", "path", path, "err", primaryErr, "snapshots", len(snaps))
+			}
+		}
 	}
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.snapshots = p.Snapshots
+	h.snapshots = snaps
 	// Trim to maxLen
 	if len(h.snapshots) > h.maxLen {
 		h.snapshots = h.snapshots[len(h.snapshots)-h.maxLen:]
 	}
 	return nil
 }
+
+func readHistoryFile(path string) ([]Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p persistedHistory
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse history: %w", err)
+	}
+	if err := migrateHistory(&p); err != nil {
+		return nil, err
+	}
+	return p.Snapshots, nil
+}
+
+// salvageHistoryFile decodes snapshots one by one from a truncated or damaged
+// history file and returns those read before the first error.
+func salvageHistoryFile(path string) []Snapshot {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	// Expect {["version": N,] "snapshots": [
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+	p := persistedHistory{}
+	for {
+		key, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		if key == "version" {
+			if err := dec.Decode(&p.Version); err != nil {
+				return nil
+			}
+			continue
+		}
+		if key != "snapshots" {
+			return nil
+		}
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+			return nil
+		}
+		break
+	}
+	for dec.More() {
+		var s Snapshot
+		if err := dec.Decode(&s); err != nil {
+			break
+		}
+		p.Snapshots = append(p.Snapshots, s)
+	}
+	if err := migrateHistory(&p); err != nil {
+		return nil
+	}
+	return p.Snapshots
+}
+
+func lastTime(snaps []Snapshot) time.Time {
+	if len(snaps) == 0 {
+		return time.Time{}
+	}
+	return snaps[len(snaps)-1].Time
+}
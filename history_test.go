@@ -0,0 +1,262 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryAddTrims(t *testing.T) {
+	h := NewHistory(3)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		h.Add(Snapshot{Time: base.Add(time.Duration(i) * time.Minute)})
+	}
+	got := h.All()
+	if len(got) != 3 {
+		t.Fatalf("len = %d, want 3", len(got))
+	}
+	if !got[0].Time.Equal(base.Add(2 * time.Minute)) {
+		t.Errorf("oldest kept = %v, want snapshot 2", got[0].Time)
+	}
+}
+
+func TestHistoryAverages(t *testing.T) {
+	tests := []struct {
+		name      string
+		snaps     []Snapshot
+		want      map[string]CoinAverage
+		wantMined MinedAverage
+	}{
+		{
+			name: "per-coin mean and mined mean",
+			snaps: []Snapshot{
+				{Coins: map[string]float64{"A": 1, "B": 4}, CoinsBTC: map[string]float64{"A": 10, "B": 40}, Mining: "A"},
+				{Coins: map[string]float64{"A": 3, "B": 2}, CoinsBTC: map[string]float64{"A": 30, "B": 20}, Mining: "B"},
+			},
+			want: map[string]CoinAverage{
+				"A": {Ticker: "A", AvgFiat: 2, AvgBTCMH: 20, Count: 2},
+				"B": {Ticker: "B", AvgFiat: 3, AvgBTCMH: 30, Count: 2},
+			},
+			// mined: A=1 then B=2
+			wantMined: MinedAverage{AvgFiat: 1.5, AvgBTCMH: 15, Count: 2},
+		},
+		{
+			name: "coin missing from some snapshots",
+			snaps: []Snapshot{
+				{Coins: map[string]float64{"A": 1}, CoinsBTC: map[string]float64{"A": 1}},
+				{Coins: map[string]float64{"A": 3, "B": 6}, CoinsBTC: map[string]float64{"A": 3, "B": 6}},
+			},
+			want: map[string]CoinAverage{
+				"A": {Ticker: "A", AvgFiat: 2, AvgBTCMH: 2, Count: 2},
+				"B": {Ticker: "B", AvgFiat: 6, AvgBTCMH: 6, Count: 1},
+			},
+		},
+		{
+			name: "aggregates weighted by samples",
+			snaps: []Snapshot{
+				{Coins: map[string]float64{"A": 1}, CoinsBTC: map[string]float64{"A": 1}, Mining: "A", Samples: 3},
+				{Coins: map[string]float64{"A": 5}, CoinsBTC: map[string]float64{"A": 5}, Mining: "A"},
+			},
+			want: map[string]CoinAverage{
+				"A": {Ticker: "A", AvgFiat: 2, AvgBTCMH: 2, Count: 4},
+			},
+			wantMined: MinedAverage{AvgFiat: 2, AvgBTCMH: 2, Count: 4},
+		},
+		{
+			name:  "mined coin without data is ignored",
+			snaps: []Snapshot{{Coins: map[string]float64{"A": 1}, Mining: "Z"}},
+			want:  map[string]CoinAverage{"A": {Ticker: "A", AvgFiat: 1, Count: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistory(100)
+			for _, s := range tt.snaps {
+				h.Add(s)
+			}
+			avgs, mined := h.Averages()
+			if len(avgs) != len(tt.want) {
+				t.Fatalf("got %d averages, want %d", len(avgs), len(tt.want))
+			}
+			for i, a := range avgs {
+				w := tt.want[a.Ticker]
+				if a.Count != w.Count || !approxEqual(a.AvgFiat, w.AvgFiat) || !approxEqual(a.AvgBTCMH, w.AvgBTCMH) {
+					t.Errorf("%s = %+v, want %+v", a.Ticker, a, w)
+				}
+				if i > 0 && avgs[i-1].AvgFiat < a.AvgFiat {
+					t.Errorf("averages not sorted by fiat: %+v", avgs)
+				}
+			}
+			if mined.Count != tt.wantMined.Count || !approxEqual(mined.AvgFiat, tt.wantMined.AvgFiat) || !approxEqual(mined.AvgBTCMH, tt.wantMined.AvgBTCMH) {
+				t.Errorf("mined = %+v, want %+v", mined, tt.wantMined)
+			}
+		})
+	}
+}
+
+func TestHistorySaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	h := NewHistory(10)
+	for _, s := range testSnapshots() {
+		h.Add(s)
+	}
+	if err := h.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Load trims to the new maxLen, keeping the newest
+	loaded := NewHistory(2)
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	got := loaded.All()
+	want := testSnapshots()[3:]
+	if len(got) != 2 || !got[0].Time.Equal(want[0].Time) || got[1].Mining != want[1].Mining {
+		t.Errorf("loaded %+v, want last two snapshots", got)
+	}
+}
+
+func TestHistoryLoadMissing(t *testing.T) {
+	err := NewHistory(10).Load(filepath.Join(t.TempDir(), "missing.json"))
+	if !os.IsNotExist(err) {
+		t.Errorf("err = %v, want not-exist", err)
+	}
+}
+
+func TestHistoryLoadRecovers(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(path string, data []byte)
+		want    int
+	}{
+		{
+			name:    "truncated primary, backup is older",
+			corrupt: func(path string, data []byte) { os.WriteFile(path, data[:len(data)-20], 0644) },
+			want:    4, // backup has 4; salvage also yields 4 complete snapshots
+		},
+		{
+			name:    "garbage primary falls back to backup",
+			corrupt: func(path string, data []byte) { os.WriteFile(path, []byte("\x00\x00"), 0644) },
+			want:    4,
+		},
+		{
+			name: "missing primary after interrupted rotation",
+			corrupt: func(path string, data []byte) {
+				os.Remove(path)
+			},
+			want: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "history.json")
+			h := NewHistory(10)
+			for _, s := range testSnapshots() {
+				h.Add(s)
+				if err := h.Save(path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.corrupt(path, data)
+			loaded := NewHistory(10)
+			if err := loaded.Load(path); err != nil {
+				t.Fatal(err)
+			}
+			if n := len(loaded.All()); n != tt.want {
+				t.Errorf("recovered %d snapshots, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryMigratesV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	v1 := `{"snapshots":[{"Time":"2026-01-01T00:00:00Z","Coins":{"XMR":1},"CoinsBTC":{"XMR":0.1},"Mining":"XMR","Switched":false}]}`
+	if err := os.WriteFile(path, []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHistory(10)
+	if err := h.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	s := h.All()[0]
+	if s.Coins["XMR"] != 1 || s.Mining != "XMR" || s.Reason != reasonBest {
+		t.Errorf("migrated snapshot = %+v", s)
+	}
+	avgs, _ := h.Averages()
+	if len(avgs) != 1 || avgs[0].AvgFiat != 1 {
+		t.Errorf("averages on v1 file = %+v", avgs)
+	}
+}
+
+func TestEfficiencyOf(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	snaps := []Snapshot{
+		{Time: t0, Mining: "XMR", CoinRevenue: map[string]float64{"XMR": 0.024}, Earned: map[string]float64{"XMR": 1}},
+		{Time: t0.Add(6 * time.Hour), Mining: "XMR", CoinRevenue: map[string]float64{"XMR": 0.024}, Earned: map[string]float64{"XMR": 1.0054, "SAL": 3}},
+		{Time: t0.Add(12 * time.Hour), Mining: "SAL", CoinRevenue: map[string]float64{"XMR": 0.024, "SAL": 8}, Earned: map[string]float64{"XMR": 1.0108, "SAL": 3}},
+		// pool reset: the interval is skipped for SAL
+		{Time: t0.Add(18 * time.Hour), Mining: "SAL", CoinRevenue: map[string]float64{"SAL": 8}, Earned: map[string]float64{"XMR": 1.0110, "SAL": 0.5}},
+		{Time: t0.Add(24 * time.Hour), Mining: "SAL", Earned: map[string]float64{"XMR": 1.0110, "SAL": 2.5}},
+	}
+	got := efficiencyOf(snaps)
+	if len(got) != 2 || got[0].Ticker != "SAL" || got[1].Ticker != "XMR" {
+		t.Fatalf("efficiencyOf = %+v, want SAL and XMR", got)
+	}
+	sal, xmr := got[0], got[1]
+	// SAL: one 6h interval with both totals while mining it: 8/4 = 2 estimated, 2 credited
+	if sal.Mined != 6*time.Hour || math.Abs(sal.Estimated-2) > 1e-9 || math.Abs(sal.Actual-2) > 1e-9 {
+		t.Errorf("SAL = %+v, want 6h, estimated 2, actual 2", sal)
+	}
+	// XMR: 12h mined, 0.012 estimated; 0.011 credited including the late credit
+	if xmr.Mined != 12*time.Hour || math.Abs(xmr.Estimated-0.012) > 1e-9 || math.Abs(xmr.Actual-0.011) > 1e-9 {
+		t.Errorf("XMR = %+v, want 12h, estimated 0.012, actual 0.011", xmr)
+	}
+	if r, ok := xmr.Ratio(); !ok || math.Abs(r-0.011/0.012) > 1e-9 {
+		t.Errorf("XMR ratio = %v, %v", r, ok)
+	}
+	if _, ok := (CoinEfficiency{Actual: 1}).Ratio(); ok {
+		t.Error("ratio without an estimate should not be ok")
+	}
+}
+
+func TestHistorySaveKeepsBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	h := NewHistory(10)
+	for _, s := range testSnapshots()[:3] {
+		h.Add(s)
+		if err := h.Save(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for p, want := range map[string]int{path: 3, path + ".bak": 2} {
+		if snaps, err := readHistoryFile(p); err != nil || len(snaps) != want {
+			t.Errorf("%s has %d snapshots (err %v), want %d", filepath.Base(p), len(snaps), err, want)
+		}
+	}
+	if matches, _ := filepath.Glob(path + "*.tmp*"); len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chart.svg")
+	for _, content := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "second" {
+		t.Errorf("content = %q (err %v), want second", got, err)
+	}
+	if matches, _ := filepath.Glob(path + ".*"); len(matches) > 0 {
+		t.Errorf("extra files left behind: %v", matches)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cmdHistory implements the `history` subcommand: averages and chart over an
+// arbitrary time range, read from the long-term store when history_db is set
+// and from history_file otherwise.
+func cmdHistory(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	since := fs.String("since", "24h", "Start of the range: a duration back from now (90m, 24h, 30d) or a date (2006-01-02)")
+	until := fs.String("until", "", "End of the range, same formats as -since (default: now)")
+	units := fs.String("units", "", "Chart units: fiat or btc (default: chart_units)")
+	width := fs.Int("width", 0, "Chart columns, 0 to fit the terminal (default: chart_width)")
+	height := fs.Int("height", 0, "Chart rows (default: chart_height)")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+	cfg, err := loadConfig(*configPath, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if *units != "" && *units != "fiat" && *units != "btc" {
+		fmt.Fprintf(os.Stderr, "-units must be fiat or btc\n")
+		return 1
+	}
+
+	now := time.Now()
+	from, err := parseTimeArg(*since, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-since: %v\n", err)
+		return 1
+	}
+	to := now
+	if *until != "" {
+		if to, err = parseTimeArg(*until, now); err != nil {
+			fmt.Fprintf(os.Stderr, "-until: %v\n", err)
+			return 1
+		}
+	}
+
+	snaps, err := loadSnapshots(cfg, from, to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if len(snaps) == 0 {
+		fmt.Printf("No history between %s and %s\n", from.Format(time.DateTime), to.Format(time.DateTime))
+		return 0
+	}
+
+	current := snaps[len(snaps)-1].Mining
+	out := styled(os.Stdout)
+	fmt.Fprintf(out, "\n  History %s → %s\n", from.Format(time.DateTime), to.Format(time.DateTime))
+	avgs, mined := averagesOf(snaps)
+	writeAverages(out, avgs, mined, cfg.FiatCurrency, current)
+	writeEfficiency(out, efficiencyOf(snaps))
+
+	// The chart buckets the whole range to fit its width.
+	opts := cfg.chartOptions()
+	opts.Window = 0
+	if *units != "" {
+		opts.BTC = *units == "btc"
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "width" {
+			opts.Width = *width
+		}
+	})
+	if *height > 0 {
+		opts.Height = *height
+	}
+	printChart(snaps, cfg.FiatCurrency, opts)
+	return 0
+}
+
+// loadSnapshots returns snapshots with from <= Time < to from the store, or
+// from the history file when no store is configured.
+func loadSnapshots(cfg *Config, from, to time.Time) ([]Snapshot, error) {
+	if cfg.HistoryDB != "" {
+		st, err := OpenStore(cfg.HistoryDB, cfg.RawRetention, cfg.HourlyRetention, cfg.DailyRetention)
+		if err != nil {
+			return nil, err
+		}
+		return st.Query(from, to)
+	}
+	hist := NewHistory(1 << 30)
+	if err := hist.Load(cfg.HistoryFile); err != nil {
+		return nil, fmt.Errorf("load history: %w", err)
+	}
+	var out []Snapshot
+	for _, s := range hist.All() {
+		if !s.Time.Before(from) && s.Time.Before(to) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// parseTimeArg accepts a duration back from now (see parseDuration), a date
+// (2006-01-02) or an RFC 3339 timestamp.
+func parseTimeArg(s string, now time.Time) (time.Time, error) {
+	if d, err := parseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use 24h, 30d, 2006-01-02 or RFC 3339)", s)
+}
+
+// parseDuration parses Go duration syntax plus a "d" suffix for days (7d).
+func parseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.ParseFloat(days, 64); err == nil {
+			return time.Duration(n * 24 * float64(time.Hour)), nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// cmdChart implements the `chart` subcommand: render the history to an SVG or
+// PNG file.
+func cmdChart(args []string) int {
+	fs := flag.NewFlagSet("chart", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	out := fs.String("o", "", "Output file, .svg or .png (default: chart_image)")
+	since := fs.String("since", "24h", "Start of the range: a duration back from now (90m, 24h, 30d) or a date (2006-01-02)")
+	until := fs.String("until", "", "End of the range, same formats as -since (default: now)")
+	units := fs.String("units", "", "Chart units: fiat or btc (default: chart_units)")
+	width := fs.Int("width", 0, "Image width in pixels (default: chart_image_width)")
+	height := fs.Int("height", 0, "Image height in pixels (default: chart_image_height)")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+	if *units != "" && *units != "fiat" && *units != "btc" {
+		fmt.Fprintf(os.Stderr, "-units must be fiat or btc\n")
+		return 1
+	}
+	cfg, err := loadConfig(*configPath, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if *out == "" {
+		*out = cfg.ChartImage
+	}
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "no output file: pass -o chart.svg or set chart_image")
+		return 1
+	}
+
+	now := time.Now()
+	from, err := parseTimeArg(*since, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-since: %v\n", err)
+		return 1
+	}
+	to := now
+	if *until != "" {
+		if to, err = parseTimeArg(*until, now); err != nil {
+			fmt.Fprintf(os.Stderr, "-until: %v\n", err)
+			return 1
+		}
+	}
+	snaps, err := loadSnapshots(cfg, from, to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	opts := cfg.chartOptions()
+	opts.Window = 0
+	if *units != "" {
+		opts.BTC = *units == "btc"
+	}
+	if *width == 0 {
+		*width = cfg.ChartImageWidth
+	}
+	if *height == 0 {
+		*height = cfg.ChartImageHeight
+	}
+	if err := writeChartImage(*out, snaps, cfg.FiatCurrency, opts, *width, *height); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s (%d snapshots)\n", *out, len(snaps))
+	return 0
+}
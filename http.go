@@ -2,17 +2,115 @@ package main
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 var httpClient = &http.Client{Timeout: 15 * time.Second}
 
+// newHTTPClient builds the client shared by the Kryptex and Ultimate Proxy calls.
+// caFile adds PEM certificates to the system pool; proxyURL overrides the
+// HTTP_PROXY/HTTPS_PROXY environment variables.
+func newHTTPClient(caFile, proxyURL string) (*http.Client, error) {
+	if caFile == "" && proxyURL == "" {
+		return &http.Client{Timeout: 15 * time.Second}, nil
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_bundle %s: no PEM certificates found", caFile)
+		}
+		tr.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid http_proxy %q", proxyURL)
+		}
+		if pw, ok := u.User.Password(); ok {
+			registerSecret(pw)
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+	return &http.Client{Timeout: 15 * time.Second, Transport: tr}, nil
+}
+
+// maxErrorBody caps how much of an error response body is kept in error messages.
+const maxErrorBody = 256
+
+var secrets struct {
+	sync.RWMutex
+	values []string
+}
+
+// registerSecret records a value that must never appear in logs or errors.
+func registerSecret(s string) {
+	if s == "" {
+		return
+	}
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, v := range secrets.values {
+		if v == s {
+			return
+		}
+	}
+	secrets.values = append(secrets.values, s)
+}
+
+// redact replaces every registered secret in s with a placeholder.
+func redact(s string) string {
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, v := range secrets.values {
+		s = strings.ReplaceAll(s, v, "[REDACTED]")
+	}
+	return s
+}
+
+// statusError builds the error for a non-success response, truncating the body
+// and scrubbing secrets that an upstream might echo back.
+func statusError(url string, status int, body io.Reader) error {
+	data, _ := io.ReadAll(io.LimitReader(body, maxErrorBody+1))
+	msg := strings.TrimSpace(string(data))
+	if len(data) > maxErrorBody {
+		msg = strings.TrimSpace(string(data[:maxErrorBody])) + "…"
+	}
+	return fmt.Errorf("request %s: status %d: %s", redact(url), status, redact(msg))
+}
+
+// requestError is a request that failed before a response arrived. Its message
+// is redacted; it unwraps to the transport error so callers can still detect
+// timeouts or cancellation with errors.Is and errors.As.
+type requestError struct {
+	url string
+	err error
+}
+
+func (e *requestError) Error() string {
+	return fmt.Sprintf("request %s: %s", redact(e.url), redact(e.err.Error()))
+}
+
+func (e *requestError) Unwrap() error { return e.err }
+
 func fetchJSON(url string, headers map[string]string, target interface{}) error {
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
@@ -23,12 +121,11 @@ func fetchJSON(url string, headers map[string]string, target interface{}) error
 	}
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("request %s: %w", url, err)
+		return &requestError{url: url, err: err}
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("request %s: status %d: %s", url, resp.StatusCode, string(body))
+		return statusError(url, resp.StatusCode, resp.Body)
 	}
 	return json.NewDecoder(resp.Body).Decode(target)
 }
@@ -40,12 +137,11 @@ func fetchFloat(url string) (float64, error) {
 	}
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("request %s: %w", url, err)
+		return 0, &requestError{url: url, err: err}
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("request %s: status %d: %s", url, resp.StatusCode, string(body))
+		return 0, statusError(url, resp.StatusCode, resp.Body)
 	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -75,12 +171,11 @@ func postJSON(url string, headers map[string]string, payload interface{}) error
 	}
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("request %s: %w", url, err)
+		return &requestError{url: url, err: err}
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("request %s: status %d: %s", url, resp.StatusCode, string(body))
+		return statusError(url, resp.StatusCode, resp.Body)
 	}
 	return nil
 }
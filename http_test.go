@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchFloat(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    float64
+		wantErr string
+	}{
+		{name: "plain", status: 200, body: "0.00123", want: 0.00123},
+		{name: "whitespace", status: 200, body: "  42.5\n", want: 42.5},
+		{name: "exponent", status: 200, body: "1e-7", want: 1e-7},
+		{name: "not a number", status: 200, body: `{"error":"x"}`, wantErr: "invalid syntax"},
+		{name: "empty", status: 200, body: "", wantErr: "invalid syntax"},
+		{name: "server error", status: 500, body: "boom", wantErr: "status 500: boom"},
+		{name: "not found", status: 404, body: "no such coin", wantErr: "status 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			got, err := fetchFloat(srv.URL)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchFloatUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+	if _, err := fetchFloat(url); err == nil {
+		t.Fatal("expected an error for a closed server")
+	}
+}
+
+func TestRequestErrorUnwraps(t *testing.T) {
+	registerSecret("up_k_timeout_key")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+	saved := httpClient
+	httpClient = &http.Client{Timeout: 20 * time.Millisecond}
+	defer func() { httpClient = saved }()
+
+	err := fetchJSON(srv.URL+"/?key=up_k_timeout_key", nil, &struct{}{})
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Fatalf("err = %v, want a timeout net.Error", err)
+	}
+	if strings.Contains(err.Error(), "up_k_timeout_key") {
+		t.Errorf("secret leaked: %s", err)
+	}
+}
+
+func TestStatusErrorRedactsAndTruncates(t *testing.T) {
+	registerSecret("up_k_secret123")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, "bad key up_k_secret123 %s", strings.Repeat("x", 1000))
+	}))
+	defer srv.Close()
+	err := fetchJSON(srv.URL, nil, &struct{}{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "up_k_secret123") {
+		t.Errorf("secret leaked: %s", msg)
+	}
+	if !strings.Contains(msg, "[REDACTED]") {
+		t.Errorf("secret not replaced: %s", msg)
+	}
+	if len(msg) > maxErrorBody+len(srv.URL)+64 {
+		t.Errorf("body not truncated (%d bytes)", len(msg))
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheRule gives the responses of URLs containing Match a time to live.
+type cacheRule struct {
+	Match string
+	TTL   time.Duration
+}
+
+// defaultCacheRules cover the Kryptex endpoints polled every cycle.
+var defaultCacheRules = map[string]string{
+	"/rates":          "1m",
+	"/daily-revenue/": "1m",
+	"/miner-stats/":   "1m",
+}
+
+// parseCacheRules turns the http_cache map into rules, longest match first so
+// the most specific rule wins.
+func parseCacheRules(m map[string]string) ([]cacheRule, error) {
+	rules := make([]cacheRule, 0, len(m))
+	for match, ttl := range m {
+		d, err := parseDuration(ttl)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("http_cache %q: invalid time to live %q", match, ttl)
+		}
+		rules = append(rules, cacheRule{Match: match, TTL: d})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].Match) != len(rules[j].Match) {
+			return len(rules[i].Match) > len(rules[j].Match)
+		}
+		return rules[i].Match < rules[j].Match
+	})
+	return rules, nil
+}
+
+// cacheEntry is a successful GET response kept by cachingTransport.
+type cacheEntry struct {
+	URL    string
+	Time   time.Time
+	Header http.Header
+	Body   []byte
+}
+
+// cachingTransport wraps the API client's transport. It rate-limits requests
+// with a token bucket per host, and caches GET responses of URLs matching a
+// rule: within the rule's time to live the cached response is returned without
+// a request, and past it the cached response is still returned, as stale, when
+// the upstream fails (network error, 429 or 5xx) and it is younger than
+// maxStale. With dir set, entries are also kept on disk so that several
+// instances on one host share them.
+type cachingTransport struct {
+	next     http.RoundTripper
+	rules    []cacheRule
+	maxStale time.Duration
+	dir      string
+	rate     float64 // requests per second per host, 0 = unlimited
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	buckets map[string]*tokenBucket
+	stale   map[string]time.Time // URL -> time of the stale response last served
+}
+
+func newCachingTransport(next http.RoundTripper, rules []cacheRule, maxStale time.Duration, dir string, rate float64) *cachingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &cachingTransport{
+		next:     next,
+		rules:    rules,
+		maxStale: maxStale,
+		dir:      dir,
+		rate:     rate,
+		entries:  make(map[string]*cacheEntry),
+		buckets:  make(map[string]*tokenBucket),
+		stale:    make(map[string]time.Time),
+	}
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.String()
+	rule, cacheable := t.rule(req)
+	var cached *cacheEntry
+	if cacheable {
+		cached = t.lookup(key)
+		if cached != nil && time.Since(cached.Time) < rule.TTL {
+			t.markFresh(key)
+			return cached.response(req), nil
+		}
+	}
+
+	if err := t.wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if !cacheable {
+		return resp, err
+	}
+	if err == nil && resp.StatusCode == http.StatusOK {
+		body, rerr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if rerr != nil {
+			return nil, rerr
+		}
+		t.store(&cacheEntry{URL: key, Time: time.Now(), Header: resp.Header, Body: body})
+		t.markFresh(key)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+	failed := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	if failed && cached != nil && time.Since(cached.Time) < t.maxStale {
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			resp.Body.Close()
+		}
+		slog.Warn("API request failed, serving cached response", "url", redact(key), "age", time.Since(cached.Time).Round(time.Second).String(), "err", redact(reason))
+		t.mu.Lock()
+		t.stale[key] = cached.Time
+		t.mu.Unlock()
+		return cached.response(req), nil
+	}
+	return resp, err
+}
+
+// rule returns the cache rule for req; only GET requests are cached.
+func (t *cachingTransport) rule(req *http.Request) (cacheRule, bool) {
+	if req.Method != http.MethodGet {
+		return cacheRule{}, false
+	}
+	u := req.URL.String()
+	for _, r := range t.rules {
+		if strings.Contains(u, r.Match) {
+			return r, true
+		}
+	}
+	return cacheRule{}, false
+}
+
+// lookup returns the newest entry for key from memory or, when it is newer
+// there, from the shared directory.
+func (t *cachingTransport) lookup(key string) *cacheEntry {
+	t.mu.Lock()
+	e := t.entries[key]
+	t.mu.Unlock()
+	if t.dir == "" {
+		return e
+	}
+	data, err := os.ReadFile(t.path(key))
+	if err != nil {
+		return e
+	}
+	var disk cacheEntry
+	if json.Unmarshal(data, &disk) != nil || disk.URL != key {
+		return e
+	}
+	if e == nil || disk.Time.After(e.Time) {
+		t.mu.Lock()
+		t.entries[key] = &disk
+		t.mu.Unlock()
+		return &disk
+	}
+	return e
+}
+
+func (t *cachingTransport) store(e *cacheEntry) {
+	t.mu.Lock()
+	t.entries[e.URL] = e
+	t.mu.Unlock()
+	if t.dir == "" {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err == nil {
+		err = writeFileAtomic(t.path(e.URL), data, 0600)
+	}
+	if err != nil {
+		slog.Warn("Failed to write HTTP cache", "dir", t.dir, "err", err)
+	}
+}
+
+// path returns the cache file of key; URLs are hashed since they may hold secrets.
+func (t *cachingTransport) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+func (t *cachingTransport) markFresh(key string) {
+	t.mu.Lock()
+	delete(t.stale, key)
+	t.mu.Unlock()
+}
+
+// staleAge returns how old the response last returned for url was, if it was
+// served stale after an upstream failure.
+func (t *cachingTransport) staleAge(url string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at, ok := t.stale[url]; ok {
+		return time.Since(at)
+	}
+	return 0
+}
+
+// wait blocks until the host's bucket grants a request.
+func (t *cachingTransport) wait(ctx context.Context, host string) error {
+	if t.rate <= 0 {
+		return nil
+	}
+	t.mu.Lock()
+	b, ok := t.buckets[host]
+	if !ok {
+		b = newTokenBucket(t.rate)
+		t.buckets[host] = b
+	}
+	d := b.reserve(time.Now())
+	t.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// staleAge returns the age of the cached response served for url after an
+// upstream failure, or 0 when it was fetched fresh.
+func staleAge(url string) time.Duration {
+	if t, ok := httpClient.Transport.(*cachingTransport); ok {
+		return t.staleAge(url)
+	}
+	return 0
+}
+
+// tokenBucket allows rate requests per second on average, in bursts of up
+// to twice that (at least one).
+type tokenBucket struct {
+	rate, burst, tokens float64
+	last                time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := math.Max(1, math.Ceil(2*rate))
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst}
+}
+
+// reserve takes a token and returns how long the caller must wait for it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer answers "<n>" for request n, or status when fail is set.
+func flakyServer(t *testing.T, fail *atomic.Bool) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		if fail.Load() {
+			http.Error(w, "upstream down", http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, n)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func get(t *testing.T, c *http.Client, url string) (string, int) {
+	t.Helper()
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body), resp.StatusCode
+}
+
+func TestCachingTransportTTL(t *testing.T) {
+	var fail atomic.Bool
+	srv, hits := flakyServer(t, &fail)
+	rules, _ := parseCacheRules(map[string]string{"/rates": "1h", "/daily-revenue/": "0"})
+	c := &http.Client{Transport: newCachingTransport(nil, rules, time.Hour, "", 0)}
+
+	get(t, c, srv.URL+"/rates")
+	if body, _ := get(t, c, srv.URL+"/rates"); body != "1" || hits.Load() != 1 {
+		t.Errorf("cached /rates: body %q after %d requests, want 1 request", body, hits.Load())
+	}
+	get(t, c, srv.URL+"/daily-revenue/XMR")
+	get(t, c, srv.URL+"/daily-revenue/XMR")
+	get(t, c, srv.URL+"/v1/workers")
+	if hits.Load() != 4 {
+		t.Errorf("%d requests, want 4: a zero TTL and unmatched URLs always go upstream", hits.Load())
+	}
+}
+
+func TestCachingTransportServesStale(t *testing.T) {
+	var fail atomic.Bool
+	srv, _ := flakyServer(t, &fail)
+	rules, _ := parseCacheRules(map[string]string{"/daily-revenue/": "0"})
+	tr := newCachingTransport(nil, rules, time.Hour, "", 0)
+	c := &http.Client{Transport: tr}
+	url := srv.URL + "/daily-revenue/XMR"
+
+	get(t, c, url)
+	fail.Store(true)
+	body, status := get(t, c, url)
+	if body != "1" || status != http.StatusOK {
+		t.Errorf("after a 502: %d %q, want the cached 200 \"1\"", status, body)
+	}
+	if tr.staleAge(url) <= 0 {
+		t.Error("stale response not flagged")
+	}
+	if _, status := get(t, c, srv.URL+"/daily-revenue/SAL"); status != http.StatusBadGateway {
+		t.Errorf("uncached URL: status %d, want the upstream 502", status)
+	}
+	fail.Store(false)
+	if body, _ := get(t, c, url); body != "4" || tr.staleAge(url) != 0 {
+		t.Errorf("after recovery: body %q, stale age %v, want fresh", body, tr.staleAge(url))
+	}
+
+	// Too old to serve
+	tr.maxStale = 0
+	fail.Store(true)
+	if _, status := get(t, c, url); status != http.StatusBadGateway {
+		t.Errorf("with max stale 0: status %d, want the upstream 502", status)
+	}
+}
+
+func TestCachingTransportSharedDir(t *testing.T) {
+	var fail atomic.Bool
+	srv, hits := flakyServer(t, &fail)
+	rules, _ := parseCacheRules(defaultCacheRules)
+	dir := t.TempDir()
+	a := &http.Client{Transport: newCachingTransport(nil, rules, time.Hour, dir, 0)}
+	b := &http.Client{Transport: newCachingTransport(nil, rules, time.Hour, dir, 0)}
+
+	get(t, a, srv.URL+"/rates")
+	if body, _ := get(t, b, srv.URL+"/rates"); body != "1" || hits.Load() != 1 {
+		t.Errorf("second instance: body %q after %d requests, want the shared response", body, hits.Load())
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(2) // burst 4
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		if d := b.reserve(now); d != 0 {
+			t.Fatalf("request %d in the burst waits %v", i+1, d)
+		}
+	}
+	if d := b.reserve(now); d != 500*time.Millisecond {
+		t.Errorf("5th request waits %v, want 500ms", d)
+	}
+	if d := b.reserve(now.Add(time.Second)); d != 0 {
+		t.Errorf("after a second: waits %v, want 0", d)
+	}
+}
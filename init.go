@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cmdInit implements the `init` subcommand: it discovers profiles and workers
+// on Ultimate Proxy, matches profiles to Kryptex tickers, asks the user to
+// confirm and writes a config that passes strict validation.
+func cmdInit(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path of the config file to write")
+	shortConfig := fs.String("c", "", "Path of the config file to write (shorthand)")
+	apiKey := fs.String("api-key", os.Getenv(envPrefix+"PROXY_API_KEY"), "Ultimate Proxy API key (prompted if empty)")
+	apiKeyFile := fs.String("api-key-file", "", "Read the API key from this file and write proxy_api_key_file instead of the key")
+	proxyURL := fs.String("proxy-base-url", "https://api.ultimate-proxy.com", "Ultimate Proxy API base URL")
+	kryptexURL := fs.String("kryptex-base-url", "https://pool.kryptex.com/api/v1", "Kryptex Pool API base URL")
+	caBundle := fs.String("ca-bundle", os.Getenv(envPrefix+"CA_BUNDLE"), "PEM file with extra CA certificates to trust (written to the config)")
+	httpProxy := fs.String("http-proxy", os.Getenv(envPrefix+"HTTP_PROXY"), "Egress proxy URL (written to the config; default: HTTP(S)_PROXY env)")
+	force := fs.Bool("force", false, "Overwrite an existing config file")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+	if _, err := os.Stat(*configPath); err == nil && !*force {
+		fmt.Printf("%s already exists (use -force to overwrite)\n", *configPath)
+		return 1
+	}
+
+	// keyValue is what the config stores: the key itself only when it was
+	// passed with -api-key or typed at the prompt
+	keyValue := *apiKey
+	if *apiKeyFile != "" {
+		key, err := os.ReadFile(*apiKeyFile)
+		if err != nil {
+			fmt.Printf("✗ read API key file: %v\n", err)
+			return 1
+		}
+		*apiKey, keyValue = strings.TrimSpace(string(key)), ""
+	} else if env := os.Getenv(envPrefix + "PROXY_API_KEY"); env != "" && *apiKey == env {
+		keyValue = "${" + envPrefix + "PROXY_API_KEY}"
+	}
+
+	p := newPrompter(os.Stdin, os.Stdout)
+	if *apiKey == "" {
+		*apiKey = p.ask("Ultimate Proxy API key (https://ultimate-proxy.com/settings/api-keys)", "")
+		if *apiKey == "" {
+			fmt.Println("An API key is required.")
+			return 1
+		}
+		keyValue = *apiKey
+	}
+	registerSecret(*apiKey)
+	client, err := newHTTPClient(*caBundle, *httpProxy)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		return 1
+	}
+	httpClient = client
+
+	fmt.Println("Fetching profiles and workers from Ultimate Proxy...")
+	profiles, err := fetchAllProfiles(*proxyURL, *apiKey)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		return 1
+	}
+	if len(profiles) == 0 {
+		fmt.Println("✗ No profiles found — create one profile per coin at https://ultimate-proxy.com/profiles first.")
+		return 1
+	}
+	workers, err := fetchAllWorkers(*proxyURL, *apiKey, "")
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		return 1
+	}
+
+	algorithm, hashrate := detectAlgorithm(workers)
+	if algorithm == "" {
+		fmt.Println("No workers connected, cannot detect the algorithm.")
+	} else {
+		fmt.Printf("Detected algorithm %s from %d worker(s) (%s).\n", algorithm, len(workers), formatHashrate(float64(hashrate)))
+	}
+	algorithm = strings.ToLower(p.ask("Algorithm", algorithm))
+	if algorithm == "" {
+		fmt.Println("An algorithm is required.")
+		return 1
+	}
+
+	fmt.Println("Fetching coins from Kryptex...")
+	rates, err := fetchRates(*kryptexURL)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		return 1
+	}
+
+	if hashrate <= 0 {
+		hashrate = 1000
+	}
+	var coins []CoinConfig
+	used := make(map[string]string) // ticker -> profile it was assigned to
+	for _, prof := range profiles {
+		if prof.Algorithm != "" && !strings.EqualFold(prof.Algorithm, algorithm) {
+			continue
+		}
+		ticker := matchTicker(prof.Name, rates.Crypto)
+		label := fmt.Sprintf("%q (%s)", prof.Name, prof.ID)
+		if _, dup := used[ticker]; dup {
+			ticker = ""
+		}
+		if ticker != "" {
+			if !p.confirm(fmt.Sprintf("Use profile %s for %s?", label, ticker), true) {
+				ticker = ""
+			}
+		}
+		if ticker == "" {
+			ticker = strings.ToUpper(p.ask(fmt.Sprintf("Kryptex ticker for profile %s (empty to skip)", label), ""))
+			if ticker == "" {
+				continue
+			}
+			if _, ok := rates.Crypto[ticker]; !ok {
+				fmt.Printf("  ! Kryptex has no rate for %s, skipping\n", ticker)
+				continue
+			}
+			if other, dup := used[ticker]; dup {
+				fmt.Printf("  ! %s is already mapped to profile %s, skipping\n", ticker, other)
+				continue
+			}
+		}
+		coin := CoinConfig{Ticker: ticker, ProfileID: prof.ID}
+		if _, err := fetchDailyRevenue(*kryptexURL, ticker, hashrate); err != nil {
+			rev := strings.ToUpper(p.ask(fmt.Sprintf("  Kryptex has no daily revenue for %s; revenue ticker (e.g. %s_RX, empty to skip)", ticker, ticker), ""))
+			if rev == "" {
+				continue
+			}
+			if _, err := fetchDailyRevenue(*kryptexURL, rev, hashrate); err != nil {
+				fmt.Printf("  ! %v, skipping\n", err)
+				continue
+			}
+			coin.RevenueTicker = rev
+		}
+		coins = append(coins, coin)
+		used[ticker] = label
+	}
+	if len(coins) == 0 {
+		fmt.Println("✗ No coins selected, nothing to write.")
+		return 1
+	}
+
+	fiat := strings.ToUpper(p.ask("Fiat currency", "USD"))
+	if _, ok := rates.Fiat[fiat]; !ok {
+		fmt.Printf("  ! Kryptex has no rate for %s, using USD\n", fiat)
+		fiat = "USD"
+	}
+	interval, err := strconv.Atoi(p.ask("Check interval in seconds", "300"))
+	if err != nil || interval <= 0 {
+		interval = 300
+	}
+
+	data := renderInitConfig(keyValue, *apiKeyFile, *proxyURL, *kryptexURL, algorithm, fiat, interval, hashrate, coins)
+	if *caBundle != "" || *httpProxy != "" {
+		data = append(data, "\n# Network\n"...)
+		if *caBundle != "" {
+			data = fmt.Appendf(data, "ca_bundle: %q\n", *caBundle)
+		}
+		if *httpProxy != "" {
+			data = fmt.Appendf(data, "http_proxy: %q\n", *httpProxy)
+		}
+	}
+	// Validate a temporary copy so a bad config never replaces the target
+	tmp := *configPath + ".new"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		fmt.Printf("✗ write config: %v\n", err)
+		return 1
+	}
+	defer os.Remove(tmp) // no-op once renamed
+	if _, err := loadConfig(tmp, true); err != nil {
+		fmt.Printf("✗ the generated config does not validate, %s was not written:\n%v\n", *configPath, err)
+		return 1
+	}
+	if err := os.Rename(tmp, *configPath); err != nil {
+		fmt.Printf("✗ write config: %v\n", err)
+		return 1
+	}
+	fmt.Printf("✓ Wrote %s with %d coin(s). Start with: ultimate-proxy-profile-switcher -c %s\n", *configPath, len(coins), *configPath)
+	return 0
+}
+
+// detectAlgorithm returns the algorithm carrying the most hashrate across
+// workers (by worker count when no hashrate is reported), and that algorithm's
+// total hashrate in H/s.
+func detectAlgorithm(workers []Worker) (string, int) {
+	hr := make(map[string]uint64)
+	count := make(map[string]int)
+	for _, w := range workers {
+		if w.Algorithm == "" {
+			continue
+		}
+		a := strings.ToLower(w.Algorithm)
+		hr[a] += w.Hashrate
+		count[a]++
+	}
+	best := ""
+	for a := range count {
+		if best == "" || hr[a] > hr[best] || (hr[a] == hr[best] && count[a] > count[best]) ||
+			(hr[a] == hr[best] && count[a] == count[best] && a < best) {
+			best = a
+		}
+	}
+	return best, int(hr[best])
+}
+
+var nameTokenPattern = regexp.MustCompile(`[A-Za-z0-9]+`)
+
+// matchTicker finds a Kryptex ticker among the words of a profile name,
+// preferring the longest match (so "XTM-RX" picks XTM, not RX).
+func matchTicker(name string, crypto map[string]float64) string {
+	var matches []string
+	for _, tok := range nameTokenPattern.FindAllString(name, -1) {
+		if _, ok := crypto[strings.ToUpper(tok)]; ok {
+			matches = append(matches, strings.ToUpper(tok))
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return len(matches[i]) > len(matches[j]) })
+	return matches[0]
+}
+
+// renderInitConfig writes a commented config in the layout of config.example.yaml.
+// apiKey may be a ${VAR} reference; a non-empty apiKeyFile is written instead.
+func renderInitConfig(apiKey, apiKeyFile, proxyURL, kryptexURL, algorithm, fiat string, interval, hashrate int, coins []CoinConfig) []byte {
+	var b strings.Builder
+	b.WriteString("# Generated by ultimate-proxy-profile-switcher\n\n")
+	b.WriteString("# Ultimate Proxy API\n")
+	if apiKeyFile != "" {
+		fmt.Fprintf(&b, "proxy_api_key_file: %q # https://ultimate-proxy.com/settings/api-keys\n", apiKeyFile)
+	} else {
+		fmt.Fprintf(&b, "proxy_api_key: %q # https://ultimate-proxy.com/settings/api-keys\n", apiKey)
+	}
+	fmt.Fprintf(&b, "proxy_algorithm: %s\n", algorithm)
+	if proxyURL != "https://api.ultimate-proxy.com" {
+		fmt.Fprintf(&b, "proxy_base_url: %q\n", proxyURL)
+	}
+	if kryptexURL != "https://pool.kryptex.com/api/v1" {
+		fmt.Fprintf(&b, "kryptex_base_url: %q\n", kryptexURL)
+	}
+	b.WriteString("\n# Fiat currency for display (USD, EUR, RUB, GBP, etc.)\n")
+	fmt.Fprintf(&b, "fiat_currency: %q\n", fiat)
+	b.WriteString("\n# Check interval in seconds\n")
+	fmt.Fprintf(&b, "interval: %d\n", interval)
+	b.WriteString("\n# Fallback hashrate (H/s) when the API returns no live data\n")
+	fmt.Fprintf(&b, "default_hashrate: %d\n", hashrate)
+	b.WriteString("\n# Coins to monitor — each maps a coin ticker to an Ultimate Proxy profile ID\ncoins:\n")
+	for _, c := range coins {
+		fmt.Fprintf(&b, "  - ticker: %q\n", c.Ticker)
+		if c.RevenueTicker != "" {
+			fmt.Fprintf(&b, "    revenue_ticker: %q\n", c.RevenueTicker)
+		}
+		fmt.Fprintf(&b, "    profile_id: %q\n", c.ProfileID)
+		if c.Wallet != "" {
+			fmt.Fprintf(&b, "    wallet: %q\n", c.Wallet)
+		}
+		b.WriteString("\n")
+	}
+	return []byte(strings.TrimRight(b.String(), "\n") + "\n")
+}
+
+// prompter asks line-based questions on a terminal.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	return &prompter{in: bufio.NewReader(in), out: out}
+}
+
+// ask prints the question with its default and returns the trimmed answer,
+// or def when the answer is empty or input is closed.
+func (p *prompter) ask(question, def string) string {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	line, _ := p.in.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return def
+	}
+	return line
+}
+
+func (p *prompter) confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	fmt.Fprintf(p.out, "%s [%s]: ", question, hint)
+	line, _ := p.in.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
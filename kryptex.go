@@ -1,6 +1,10 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
 
 // KryptexRates holds fiat and crypto exchange rates from the Kryptex Pool API.
 type KryptexRates struct {
@@ -8,19 +12,62 @@ type KryptexRates struct {
 	Crypto map[string]float64 `json:"crypto"`
 }
 
+func ratesURL(baseURL string) string {
+	return baseURL + "/rates"
+}
+
 func fetchRates(baseURL string) (*KryptexRates, error) {
 	var rates KryptexRates
-	if err := fetchJSON(baseURL+"/rates", nil, &rates); err != nil {
+	if err := fetchJSON(ratesURL(baseURL), nil, &rates); err != nil {
 		return nil, fmt.Errorf("fetch rates: %w", err)
 	}
 	return &rates, nil
 }
 
+func revenueURL(baseURL, ticker string, hashrate int) string {
+	return fmt.Sprintf("%s/daily-revenue/%s?hashrate=%d", baseURL, ticker, hashrate)
+}
+
 func fetchDailyRevenue(baseURL, ticker string, hashrate int) (float64, error) {
-	url := fmt.Sprintf("%s/daily-revenue/%s?hashrate=%d", baseURL, ticker, hashrate)
-	rev, err := fetchFloat(url)
+	rev, err := fetchFloat(revenueURL(baseURL, ticker, hashrate))
 	if err != nil {
 		return 0, fmt.Errorf("fetch revenue %s: %w", ticker, err)
 	}
 	return rev, nil
 }
+
+// MinerStats is the pool's view of one wallet on one coin: what was actually
+// credited, as opposed to the daily-revenue estimate.
+type MinerStats struct {
+	Unpaid   float64 // balance not yet paid out, in coin
+	Paid     float64 // total paid out, in coin
+	Hashrate float64 // hashrate reported by the pool, H/s
+}
+
+// Earned returns everything the pool has credited so far (paid + unpaid).
+func (s MinerStats) Earned() float64 {
+	return s.Paid + s.Unpaid
+}
+
+// fetchMinerStats reads the stats of wallet for ticker from urlTemplate, in
+// which {coin} is replaced by the lower-case ticker and {wallet} by the wallet.
+// The response must be a JSON object with unpaid, paid and hashrate numbers.
+func fetchMinerStats(urlTemplate, ticker, wallet string) (*MinerStats, error) {
+	u := strings.NewReplacer("{coin}", strings.ToLower(ticker), "{wallet}", url.PathEscape(wallet)).Replace(urlTemplate)
+	var doc interface{}
+	if err := fetchJSON(u, nil, &doc); err != nil {
+		return nil, fmt.Errorf("fetch miner stats %s: %w", ticker, err)
+	}
+	var stats MinerStats
+	for _, f := range []struct {
+		key string
+		dst *float64
+	}{{"unpaid", &stats.Unpaid}, {"paid", &stats.Paid}, {"hashrate", &stats.Hashrate}} {
+		v, err := jsonNumber(doc, f.key)
+		if err != nil {
+			return nil, fmt.Errorf("fetch miner stats %s: %w", ticker, err)
+		}
+		*f.dst = v
+	}
+	return &stats, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// LastKnown remembers each coin's last fresh profitability so that a coin
+// failing to fetch for a few cycles keeps its place in the ranking instead of
+// disappearing, which would make the current coin look abandoned.
+type LastKnown struct {
+	MaxAge time.Duration // how long a coin is kept on its last values (0 = never)
+
+	coins map[string]knownCoin
+}
+
+type knownCoin struct {
+	prof     CoinProfitability
+	at       time.Time // when the values were fetched
+	hashrate float64   // hashrate DailyRevCoin was computed for
+}
+
+func NewLastKnown(maxAge time.Duration) *LastKnown {
+	return &LastKnown{MaxAge: maxAge, coins: make(map[string]knownCoin)}
+}
+
+// Prime restores the last recorded values of each configured coin from
+// history, so that a coin failing right after a restart is still ranked.
+func (k *LastKnown) Prime(snaps []Snapshot, coins []CoinConfig) {
+	for _, c := range coins {
+		for i := len(snaps) - 1; i >= 0; i-- {
+			s := snaps[i]
+			v, ok := s.Coins[c.Ticker]
+			if !ok {
+				continue
+			}
+			k.coins[c.Ticker] = knownCoin{
+				prof: CoinProfitability{
+					Ticker:           c.Ticker,
+					DailyRevCoin:     s.CoinRevenue[c.Ticker],
+					CryptoRateUSD:    s.Prices[c.Ticker],
+					DailyRevenueFiat: v,
+					BTCPerMHDay:      s.CoinsBTC[c.Ticker],
+					FiatRate:         s.FiatRate,
+				},
+				at:       s.Time,
+				hashrate: s.Hashrate,
+			}
+			break
+		}
+	}
+}
+
+// Fill remembers the coins of profs and adds the configured coins missing
+// from it from their last known values, scaled to hashrate and marked with
+// their age in FailedAge. Coins last seen more than MaxAge ago stay out. The
+// result is sorted like computeProfitability's.
+func (k *LastKnown) Fill(now time.Time, profs []CoinProfitability, coins []CoinConfig, hashrate int) []CoinProfitability {
+	fresh := make(map[string]bool, len(profs))
+	for _, p := range profs {
+		fresh[p.Ticker] = true
+		// Cached responses are as old as the cache entry, not as the cycle
+		at := now.Add(-p.StaleAge)
+		if prev, ok := k.coins[p.Ticker]; !ok || !prev.at.After(at) {
+			k.coins[p.Ticker] = knownCoin{prof: p, at: at, hashrate: float64(hashrate)}
+		}
+	}
+	if k.MaxAge <= 0 {
+		return profs
+	}
+	for _, c := range coins {
+		known, ok := k.coins[c.Ticker]
+		if fresh[c.Ticker] || !ok {
+			continue
+		}
+		age := now.Sub(known.at)
+		if age > k.MaxAge {
+			continue
+		}
+		p := known.prof
+		p.ProfileID, p.PayoutThreshold = c.ProfileID, c.PayoutThreshold
+		p.StaleAge, p.FailedAge = 0, max(age, time.Second)
+		// Revenue is linear in hashrate; BTC/MH/day is already per MH
+		if known.hashrate > 0 && hashrate > 0 {
+			scale := float64(hashrate) / known.hashrate
+			p.DailyRevCoin *= scale
+			p.DailyRevenueFiat *= scale
+		}
+		slog.Info("Keeping last known values", "ticker", c.Ticker, "age", age.Round(time.Second).String())
+		profs = append(profs, p)
+	}
+	sort.SliceStable(profs, func(i, j int) bool {
+		return profs[i].DailyRevenueFiat > profs[j].DailyRevenueFiat
+	})
+	return profs
+}
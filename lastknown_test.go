@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLastKnownFill(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	coins := []CoinConfig{{Ticker: "XMR", ProfileID: "p-xmr"}, {Ticker: "SAL", ProfileID: "p-sal"}}
+	k := NewLastKnown(time.Hour)
+
+	both := []CoinProfitability{
+		{Ticker: "XMR", ProfileID: "p-xmr", DailyRevCoin: 0.001, DailyRevenueFiat: 0.3, BTCPerMHDay: 4e-6},
+		{Ticker: "SAL", ProfileID: "p-sal", DailyRevCoin: 2, DailyRevenueFiat: 0.1, BTCPerMHDay: 2e-6},
+	}
+	if got := k.Fill(t0, both, coins, 1000); len(got) != 2 {
+		t.Fatalf("fresh coins changed: %+v", got)
+	}
+
+	// XMR fails at twice the hashrate: kept, scaled and ranked first
+	got := k.Fill(t0.Add(10*time.Minute), both[1:], coins, 2000)
+	if len(got) != 2 || got[0].Ticker != "XMR" {
+		t.Fatalf("got %+v, want XMR kept first", got)
+	}
+	x := got[0]
+	if x.FailedAge != 10*time.Minute || x.StaleAge != 0 {
+		t.Errorf("FailedAge = %v, want 10m", x.FailedAge)
+	}
+	if !approxEqual(x.DailyRevenueFiat, 0.6) || !approxEqual(x.DailyRevCoin, 0.002) || !approxEqual(x.BTCPerMHDay, 4e-6) {
+		t.Errorf("kept values = %+v, want revenue scaled to the hashrate", x)
+	}
+
+	// too old to be kept
+	if got := k.Fill(t0.Add(61*time.Minute), both[1:], coins, 1000); len(got) != 1 {
+		t.Errorf("coin kept past MaxAge: %+v", got)
+	}
+
+	// off
+	k.MaxAge = 0
+	if got := k.Fill(t0.Add(62*time.Minute), both[1:], coins, 1000); len(got) != 1 {
+		t.Errorf("coin kept with MaxAge 0: %+v", got)
+	}
+}
+
+func TestLastKnownPrime(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	snaps := []Snapshot{
+		{Time: t0, Hashrate: 1000, FiatRate: 1, Coins: map[string]float64{"XMR": 0.2, "SAL": 0.1}, CoinRevenue: map[string]float64{"XMR": 0.001, "SAL": 2}},
+		{Time: t0.Add(5 * time.Minute), Hashrate: 1000, FiatRate: 1, Coins: map[string]float64{"SAL": 0.12}, CoinRevenue: map[string]float64{"SAL": 2.4}},
+	}
+	coins := []CoinConfig{{Ticker: "XMR", ProfileID: "p-xmr"}, {Ticker: "SAL", ProfileID: "p-sal"}}
+	k := NewLastKnown(time.Hour)
+	k.Prime(snaps, coins)
+
+	// after a restart nothing is fetched: both come from history
+	got := k.Fill(t0.Add(15*time.Minute), nil, coins, 1000)
+	if len(got) != 2 {
+		t.Fatalf("got %+v, want both coins", got)
+	}
+	if got[0].Ticker != "XMR" || got[0].ProfileID != "p-xmr" || got[0].FailedAge != 15*time.Minute {
+		t.Errorf("XMR = %+v, want the first snapshot's values, 15m old", got[0])
+	}
+	if got[1].Ticker != "SAL" || got[1].DailyRevenueFiat != 0.12 || got[1].FailedAge != 10*time.Minute {
+		t.Errorf("SAL = %+v, want the latest snapshot's values, 10m old", got[1])
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+)
+
+// setupLogging installs the default slog logger. format is "text" or "json";
+// level is debug, info, warn or error. Secrets are scrubbed from every record.
+func setupLogging(w io.Writer, format, level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q (use debug, info, warn or error)", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q (use text or json)", format)
+	}
+	slog.SetDefault(slog.New(redactHandler{h}))
+	return nil
+}
+
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// redactHandler scrubs registered secrets from messages and string or error
+// attributes before passing records on.
+type redactHandler struct {
+	next slog.Handler
+}
+
+func (h redactHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = redactAttr(a)
+	}
+	return redactHandler{h.next.WithAttrs(clean)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.next.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, redact(err.Error()))
+		}
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		clean := make([]any, len(attrs))
+		for i, ga := range attrs {
+			clean[i] = redactAttr(ga)
+		}
+		return slog.Group(a.Key, clean...)
+	}
+	return a
+}
+
+// logRanking logs the profitability of every coin as one record: at info level
+// when the table is suppressed, at debug level otherwise.
+func logRanking(profs []CoinProfitability, fiat string, hashrate int, quiet bool) {
+	level := slog.LevelDebug
+	if quiet {
+		level = slog.LevelInfo
+	}
+	coins := make([]any, 0, len(profs))
+	var stale, failed []string
+	for _, p := range profs {
+		coins = append(coins, slog.Float64(p.Ticker, p.DailyRevenueFiat))
+		if p.StaleAge > 0 {
+			stale = append(stale, p.Ticker)
+		}
+		if p.FailedAge > 0 {
+			failed = append(failed, p.Ticker)
+		}
+	}
+	attrs := []any{"hashrate", hashrate, "fiat", strings.ToUpper(fiat), slog.Group("daily_revenue", coins...)}
+	if len(stale) > 0 {
+		attrs = append(attrs, "stale", strings.Join(stale, ","))
+	}
+	if len(failed) > 0 {
+		attrs = append(attrs, "failed", strings.Join(failed, ","))
+	}
+	if len(profs) > 0 {
+		attrs = append(attrs, "best", profs[0].Ticker)
+	}
+	slog.Log(context.Background(), level, "Profitability", attrs...)
+}
+
+// round2 rounds to two decimals for human-friendly percentages in logs.
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// coinBalance returns the unpaid balance of c: from its balance_url when set,
+// otherwise the manually entered unpaid_balance.
+func coinBalance(c CoinConfig) (float64, error) {
+	if c.BalanceURL == "" {
+		return c.UnpaidBalance, nil
+	}
+	var doc interface{}
+	if err := fetchJSON(c.BalanceURL, nil, &doc); err != nil {
+		return 0, err
+	}
+	return jsonNumber(doc, c.BalanceField)
+}
+
+// jsonNumber follows a dot-separated path (object keys or array indexes)
+// through a decoded JSON document and returns the number found there.
+// Numeric strings are accepted since many pool APIs quote amounts.
+func jsonNumber(doc interface{}, path string) (float64, error) {
+	v := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return 0, fmt.Errorf("field %q: no key %q", path, key)
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return 0, fmt.Errorf("field %q: bad index %q", path, key)
+			}
+			v = node[i]
+		default:
+			return 0, fmt.Errorf("field %q: %q is not an object or array", path, key)
+		}
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, fmt.Errorf("field %q: %q is not a number", path, n)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("field %q is not a number", path)
+}
+
+// DaysToPayout estimates how long mining p full time takes to reach the payout
+// threshold. ok is false when no threshold is configured or p earns nothing.
+func (p CoinProfitability) DaysToPayout() (days float64, ok bool) {
+	if p.PayoutThreshold <= 0 || p.DailyRevCoin <= 0 {
+		return 0, false
+	}
+	return math.Max(p.PayoutThreshold-p.Unpaid, 0) / p.DailyRevCoin, true
+}
+
+// daysToPayout returns the days to payout of every coin that has a threshold,
+// as used by Decider.DaysToPayout.
+func daysToPayout(profs []CoinProfitability) map[string]float64 {
+	days := make(map[string]float64)
+	for _, p := range profs {
+		if d, ok := p.DaysToPayout(); ok {
+			days[p.Ticker] = d
+		}
+	}
+	return days
+}
+
+// formatDays renders a days-to-payout estimate for the table.
+func formatDays(d float64) string {
+	switch {
+	case d <= 0:
+		return "reached"
+	case d < 1:
+		return fmt.Sprintf("%.0f h", math.Ceil(d*24))
+	case d < 1000:
+		return fmt.Sprintf("%.1f d", d)
+	default:
+		return ">999 d"
+	}
+}
+
+// logPayout logs the payout progress of each coin with a threshold at debug level.
+func logPayout(profs []CoinProfitability) {
+	for _, p := range profs {
+		if d, ok := p.DaysToPayout(); ok {
+			slog.Debug("Payout progress", "ticker", p.Ticker, "unpaid", p.Unpaid, "threshold", p.PayoutThreshold, "days_to_payout", round2(d))
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PriceProvider returns USD prices for coin tickers. Tickers the provider does
+// not know are left out of the result; a partial result may come with an
+// error describing the tickers that failed.
+type PriceProvider interface {
+	Name() string
+	Prices(tickers []string) (map[string]float64, error)
+}
+
+// PriceSourceConfig configures one price source in price_sources.
+type PriceSourceConfig struct {
+	Name    string            `yaml:"name"`
+	Type    string            `yaml:"type"`              // ticker or coingecko
+	URL     string            `yaml:"url"`               // ticker: {COIN}/{coin} are replaced by the symbol
+	Field   string            `yaml:"field,omitempty"`   // ticker: dot path to the price, may contain {COIN}/{coin}
+	Symbols map[string]string `yaml:"symbols,omitempty"` // ticker -> exchange symbol (ticker) or coin id (coingecko)
+}
+
+const defaultCoinGeckoURL = "https://api.coingecko.com/api/v3/simple/price"
+
+// newPriceProvider builds the provider described by sc.
+func newPriceProvider(sc PriceSourceConfig) (PriceProvider, error) {
+	symbols := make(map[string]string, len(sc.Symbols))
+	for t, s := range sc.Symbols {
+		symbols[strings.ToUpper(t)] = s
+	}
+	switch strings.ToLower(sc.Type) {
+	case "ticker":
+		if sc.URL == "" || sc.Field == "" {
+			return nil, fmt.Errorf("price source %q: a ticker source needs url and field", sc.Name)
+		}
+		return &tickerPrices{name: sc.Name, url: sc.URL, field: sc.Field, symbols: symbols}, nil
+	case "coingecko":
+		if len(symbols) == 0 {
+			return nil, fmt.Errorf("price source %q: a coingecko source needs symbols (ticker: coin id)", sc.Name)
+		}
+		u := sc.URL
+		if u == "" {
+			u = defaultCoinGeckoURL
+		}
+		return &coinGeckoPrices{name: sc.Name, url: u, ids: symbols}, nil
+	}
+	return nil, fmt.Errorf("price source %q: unknown type %q (use ticker or coingecko)", sc.Name, sc.Type)
+}
+
+// kryptexPrices serves the crypto rates already fetched from Kryptex /rates.
+type kryptexPrices struct {
+	rates *KryptexRates
+}
+
+func (k kryptexPrices) Name() string { return "kryptex" }
+
+func (k kryptexPrices) Prices(tickers []string) (map[string]float64, error) {
+	out := make(map[string]float64, len(tickers))
+	for _, t := range tickers {
+		if p, ok := k.rates.Crypto[t]; ok {
+			out[t] = p
+		}
+	}
+	return out, nil
+}
+
+// tickerPrices queries an exchange ticker endpoint once per coin, e.g.
+// https://api.binance.com/api/v3/ticker/price?symbol={COIN}USDT with field price.
+type tickerPrices struct {
+	name, url, field string
+	symbols          map[string]string
+}
+
+func (p *tickerPrices) Name() string { return p.name }
+
+func (p *tickerPrices) Prices(tickers []string) (map[string]float64, error) {
+	out := make(map[string]float64, len(tickers))
+	var errs []error
+	for _, t := range tickers {
+		sym := t
+		if s, ok := p.symbols[t]; ok {
+			sym = s
+		}
+		r := strings.NewReplacer("{COIN}", strings.ToUpper(sym), "{coin}", strings.ToLower(sym))
+		var doc interface{}
+		if err := fetchJSON(r.Replace(p.url), nil, &doc); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t, err))
+			continue
+		}
+		price, err := jsonNumber(doc, r.Replace(p.field))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t, err))
+			continue
+		}
+		out[t] = price
+	}
+	return out, errors.Join(errs...)
+}
+
+// coinGeckoPrices queries a CoinGecko-style simple price endpoint for all
+// coins at once: GET url?ids=monero,bitcoin&vs_currencies=usd returning
+// {"monero":{"usd":150.1},...}. Coins without an id are not priced.
+type coinGeckoPrices struct {
+	name, url string
+	ids       map[string]string
+}
+
+func (p *coinGeckoPrices) Name() string { return p.name }
+
+func (p *coinGeckoPrices) Prices(tickers []string) (map[string]float64, error) {
+	var ids []string
+	for _, t := range tickers {
+		if id, ok := p.ids[t]; ok {
+			ids = append(ids, id)
+		}
+	}
+	out := make(map[string]float64, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	sep := "?"
+	if strings.Contains(p.url, "?") {
+		sep = "&"
+	}
+	u := p.url + sep + "ids=" + url.QueryEscape(strings.Join(ids, ",")) + "&vs_currencies=usd"
+	var doc interface{}
+	if err := fetchJSON(u, nil, &doc); err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, t := range tickers {
+		id, ok := p.ids[t]
+		if !ok {
+			continue
+		}
+		price, err := jsonNumber(doc, id+".usd")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t, err))
+			continue
+		}
+		out[t] = price
+	}
+	return out, errors.Join(errs...)
+}
+
+// PriceQuote is the price of one coin aggregated over every source that
+// returned it.
+type PriceQuote struct {
+	Price   float64            // median across sources
+	Spread  float64            // (max - min) / median, in percent
+	Sources map[string]float64 // source name -> price
+}
+
+// Disagree reports whether the sources differ by more than maxSpread percent.
+// A single source never disagrees, and a maxSpread of 0 or less disables the
+// check (the config maps 0 to its default, so users disable it with -1).
+func (q PriceQuote) Disagree(maxSpread float64) bool {
+	return maxSpread > 0 && len(q.Sources) > 1 && q.Spread > maxSpread
+}
+
+// String lists the sources and their prices, e.g. "coingecko=151.2 kryptex=139.8".
+func (q PriceQuote) String() string {
+	names := make([]string, 0, len(q.Sources))
+	for n := range q.Sources {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = fmt.Sprintf("%s=%.6g", n, q.Sources[n])
+	}
+	return strings.Join(parts, " ")
+}
+
+// fetchPrices asks every provider for tickers concurrently and aggregates the
+// answers per ticker. Provider errors are logged and the provider's other
+// prices still count; a ticker no provider priced is absent from the result.
+func fetchPrices(providers []PriceProvider, tickers []string) map[string]PriceQuote {
+	results := make([]map[string]float64, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p PriceProvider) {
+			defer wg.Done()
+			prices, err := p.Prices(tickers)
+			if err != nil {
+				slog.Warn("Price source failed", "source", p.Name(), "err", err)
+			}
+			results[i] = prices
+		}(i, p)
+	}
+	wg.Wait()
+
+	quotes := make(map[string]PriceQuote, len(tickers))
+	for _, t := range tickers {
+		q := PriceQuote{Sources: make(map[string]float64)}
+		var vals []float64
+		for i, p := range providers {
+			if v, ok := results[i][t]; ok && v > 0 {
+				q.Sources[p.Name()] = v
+				vals = append(vals, v)
+			}
+		}
+		if len(vals) == 0 {
+			continue
+		}
+		q.Price = median(vals)
+		lo, hi := vals[0], vals[0]
+		for _, v := range vals {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		q.Spread = (hi - lo) / q.Price * 100
+		quotes[t] = q
+	}
+	return quotes
+}
+
+// median returns the median of vals, averaging the middle pair for an even count.
+func median(vals []float64) float64 {
+	s := append([]float64(nil), vals...)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubPrices is a PriceProvider with fixed answers.
+type stubPrices struct {
+	name   string
+	prices map[string]float64
+	err    error
+}
+
+func (s stubPrices) Name() string { return s.name }
+
+func (s stubPrices) Prices(tickers []string) (map[string]float64, error) {
+	return s.prices, s.err
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		vals []float64
+		want float64
+	}{
+		{[]float64{3}, 3},
+		{[]float64{5, 1, 3}, 3},
+		{[]float64{4, 1, 3, 2}, 2.5},
+	}
+	for _, tt := range tests {
+		if got := median(tt.vals); got != tt.want {
+			t.Errorf("median(%v) = %v, want %v", tt.vals, got, tt.want)
+		}
+	}
+}
+
+func TestFetchPrices(t *testing.T) {
+	providers := []PriceProvider{
+		stubPrices{name: "kryptex", prices: map[string]float64{"XMR": 140, "SAL": 0.05, "XTM": 0}},
+		stubPrices{name: "a", prices: map[string]float64{"XMR": 150}},
+		// a failing source still contributes what it returned
+		stubPrices{name: "b", prices: map[string]float64{"XMR": 152}, err: errors.New("SAL: timeout")},
+	}
+	quotes := fetchPrices(providers, []string{"XMR", "SAL", "XTM", "ZEPH"})
+
+	xmr := quotes["XMR"]
+	if xmr.Price != 150 || len(xmr.Sources) != 3 {
+		t.Errorf("XMR = %+v, want the median 150 of 3 sources", xmr)
+	}
+	if want := 12.0 / 150 * 100; math.Abs(xmr.Spread-want) > 1e-9 {
+		t.Errorf("XMR spread = %v, want %v", xmr.Spread, want)
+	}
+	if !xmr.Disagree(5) || xmr.Disagree(10) || xmr.Disagree(0) || xmr.Disagree(-1) {
+		t.Errorf("XMR Disagree: spread %.2f%% against 5/10/0/-1", xmr.Spread)
+	}
+	if got := xmr.String(); got != "a=150 b=152 kryptex=140" {
+		t.Errorf("String() = %q", got)
+	}
+	if sal := quotes["SAL"]; sal.Price != 0.05 || sal.Disagree(1) {
+		t.Errorf("SAL = %+v, want a single source that never disagrees", sal)
+	}
+	// zero prices and unknown tickers are not quoted
+	if _, ok := quotes["XTM"]; ok {
+		t.Error("XTM with a zero price should not be quoted")
+	}
+	if _, ok := quotes["ZEPH"]; ok {
+		t.Error("ZEPH should not be quoted")
+	}
+}
+
+func TestPriceProviders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ticker/XMRUSDT":
+			fmt.Fprint(w, `{"symbol":"XMRUSDT","price":"151.5"}`)
+		case "/ticker/TARIUSDT":
+			fmt.Fprint(w, `{"symbol":"TARIUSDT","price":"0.0012"}`)
+		case "/simple/price":
+			if got := r.URL.Query().Get("ids"); got != "monero" || r.URL.Query().Get("vs_currencies") != "usd" {
+				t.Errorf("coingecko query = %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"monero":{"usd":149.9}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ticker, err := newPriceProvider(PriceSourceConfig{Name: "ex", Type: "ticker", URL: srv.URL + "/ticker/{COIN}USDT", Field: "price", Symbols: map[string]string{"xtm": "tari"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ticker.Prices([]string{"XMR", "XTM", "SAL"})
+	if err == nil {
+		t.Error("expected an error for SAL")
+	}
+	if got["XMR"] != 151.5 || got["XTM"] != 0.0012 || len(got) != 2 {
+		t.Errorf("ticker prices = %v", got)
+	}
+
+	gecko, err := newPriceProvider(PriceSourceConfig{Name: "cg", Type: "coingecko", URL: srv.URL + "/simple/price", Symbols: map[string]string{"XMR": "monero"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = gecko.Prices([]string{"XMR", "SAL"})
+	if err != nil || got["XMR"] != 149.9 || len(got) != 1 {
+		t.Errorf("coingecko prices = %v, %v", got, err)
+	}
+
+	for _, sc := range []PriceSourceConfig{
+		{Name: "x", Type: "ticker", URL: srv.URL},
+		{Name: "x", Type: "coingecko"},
+		{Name: "x", Type: "ftp"},
+	} {
+		if _, err := newPriceProvider(sc); err == nil {
+			t.Errorf("newPriceProvider(%+v) should fail", sc)
+		}
+	}
+}
+
+func TestComputeProfitabilitySkipsDisagreeingPrices(t *testing.T) {
+	kryptex := kryptexStub(t, map[string]float64{"USD": 1}, map[string]float64{"BTC": 50000, "XMR": 200, "SAL": 0.05},
+		map[string]float64{"XMR": 0.001, "SAL": 2})
+	cfg := &Config{
+		KryptexBaseURL: kryptex.URL,
+		FiatCurrency:   "USD",
+		PriceMaxSpread: 10,
+		Coins: []CoinConfig{
+			{Ticker: "XMR", ProfileID: "p-xmr"},
+			{Ticker: "SAL", ProfileID: "p-sal"},
+		},
+		priceSources: []PriceProvider{
+			stubPrices{name: "a", prices: map[string]float64{"XMR": 150, "SAL": 0.051, "BTC": 50000}},
+			stubPrices{name: "b", prices: map[string]float64{"XMR": 151, "SAL": 0.052}},
+		},
+	}
+	profs, err := computeProfitability(cfg, 1_000_000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profs) != 1 || profs[0].Ticker != "SAL" {
+		t.Fatalf("profs = %+v, want only SAL (XMR's Kryptex price is stale)", profs)
+	}
+	if profs[0].CryptoRateUSD != 0.051 {
+		t.Errorf("SAL price = %v, want the median 0.051", profs[0].CryptoRateUSD)
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -16,7 +18,30 @@ type CoinProfitability struct {
 	DailyRevCoin     float64
 	CryptoRateUSD    float64
 	DailyRevenueFiat float64
-	BTCPerMHDay      float64 // BTC equivalent per MH/day
+	BTCPerMHDay      float64       // BTC equivalent per MH/day
+	FiatRate         float64       // USD per unit of the display fiat currency
+	PayoutThreshold  float64       // pool payout minimum in coin (0 = not configured)
+	Unpaid           float64       // unpaid pool balance in coin
+	Account          *MinerStats   // pool stats of the coin's wallet (nil = no wallet or unavailable)
+	StaleAge         time.Duration // age of cached Kryptex data served after an API error (0 = fresh)
+	FailedAge        time.Duration // the coin failed this cycle; age of the last known values shown (0 = fetched)
+	Unconfirmed      bool          // outlier the anomaly guard would quarantine, shown by one-shot commands
+}
+
+// dataStatus describes values that were not fetched fresh this cycle:
+// "stale 12m" for cached API responses, "failed 12m" for the last known
+// values of a coin that failed, "unconfirmed" for an outlier shown by a
+// one-shot command, "" otherwise.
+func (p CoinProfitability) dataStatus() string {
+	switch {
+	case p.FailedAge > 0:
+		return "failed " + formatAge(p.FailedAge)
+	case p.StaleAge > 0:
+		return "stale " + formatAge(p.StaleAge)
+	case p.Unconfirmed:
+		return "unconfirmed"
+	}
+	return ""
 }
 
 // formatHashrate returns a human-readable hashrate string (H/s, KH/s, MH/s, GH/s, TH/s).
@@ -35,9 +60,24 @@ func formatHashrate(h float64) string {
 	}
 }
 
+// formatAge returns a short age for table cells: 45s, 12m, 3h or 2d.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
 // computeProfitability fetches live rates and daily revenue for all configured coins
-// and returns them sorted from most to least profitable.
-func computeProfitability(cfg *Config, hashrate int) ([]CoinProfitability, error) {
+// and returns them sorted from most to least profitable. Coins whose revenue
+// guard quarantines are dropped like coins that failed to fetch; guard may be nil.
+func computeProfitability(cfg *Config, hashrate int, guard *AnomalyGuard) ([]CoinProfitability, error) {
 	rates, err := fetchRates(cfg.KryptexBaseURL)
 	if err != nil {
 		return nil, err
@@ -48,10 +88,21 @@ func computeProfitability(cfg *Config, hashrate int) ([]CoinProfitability, error
 		return nil, fmt.Errorf("unknown fiat currency: %s", cfg.FiatCurrency)
 	}
 
-	btcRate, ok := rates.Crypto["BTC"]
+	// Coin prices: the median of Kryptex and the configured price sources
+	tickers := []string{"BTC"}
+	for _, c := range cfg.Coins {
+		tickers = append(tickers, c.Ticker)
+	}
+	quotes := fetchPrices(append([]PriceProvider{kryptexPrices{rates}}, cfg.priceSources...), tickers)
+
+	btc, ok := quotes["BTC"]
 	if !ok {
 		return nil, fmt.Errorf("BTC rate not found in rates")
 	}
+	if btc.Disagree(cfg.PriceMaxSpread) {
+		slog.Warn("BTC price sources disagree, using the median", "spread_pct", round2(btc.Spread), "sources", btc.String())
+	}
+	btcRate := btc.Price
 
 	type result struct {
 		prof CoinProfitability
@@ -76,11 +127,34 @@ func computeProfitability(cfg *Config, hashrate int) ([]CoinProfitability, error
 				return
 			}
 			// Always use the base ticker for rate lookup
-			cryptoRate, ok := rates.Crypto[c.Ticker]
+			quote, ok := quotes[c.Ticker]
 			if !ok {
 				results[idx] = result{err: fmt.Errorf("no crypto rate for %s", c.Ticker)}
 				return
 			}
+			// Sources this far apart mean one of them is stale: skip the coin rather than guess
+			if quote.Disagree(cfg.PriceMaxSpread) {
+				results[idx] = result{err: fmt.Errorf("price sources disagree by %.1f%% (max %g%%): %s", quote.Spread, cfg.PriceMaxSpread, quote)}
+				return
+			}
+			cryptoRate := quote.Price
+			stale := max(staleAge(ratesURL(cfg.KryptexBaseURL)), staleAge(revenueURL(cfg.KryptexBaseURL, revTicker, hashrate)))
+			// Account stats and balances only inform reports and payouts, never the ranking
+			var account *MinerStats
+			if c.Wallet != "" && cfg.KryptexStatsURL != "" {
+				if account, err = fetchMinerStats(cfg.KryptexStatsURL, c.Ticker, c.Wallet); err != nil {
+					slog.Warn("Failed to fetch miner stats", "ticker", c.Ticker, "err", err)
+				}
+			}
+			unpaid := 0.0
+			if c.PayoutThreshold > 0 && account != nil && c.BalanceURL == "" {
+				unpaid = account.Unpaid
+			} else if c.PayoutThreshold > 0 {
+				if unpaid, err = coinBalance(c); err != nil {
+					slog.Warn("Failed to fetch unpaid balance", "ticker", c.Ticker, "err", err)
+					unpaid = c.UnpaidBalance
+				}
+			}
 			// daily_revenue (in coin) × coin_price_in_USD / fiat_rate
 			fiatRevenue := rev * cryptoRate / fiatRate
 
@@ -96,6 +170,11 @@ func computeProfitability(cfg *Config, hashrate int) ([]CoinProfitability, error
 					CryptoRateUSD:    cryptoRate,
 					DailyRevenueFiat: fiatRevenue,
 					BTCPerMHDay:      btcPerMHDay,
+					FiatRate:         fiatRate,
+					PayoutThreshold:  c.PayoutThreshold,
+					Unpaid:           unpaid,
+					Account:          account,
+					StaleAge:         stale,
 				},
 			}
 		}(i, coin)
@@ -104,9 +183,12 @@ func computeProfitability(cfg *Config, hashrate int) ([]CoinProfitability, error
 	wg.Wait()
 
 	var profs []CoinProfitability
-	for _, r := range results {
+	for i, r := range results {
+		if r.err == nil {
+			r.err = guard.Check(r.prof, hashrate)
+		}
 		if r.err != nil {
-			log.Printf("[WARN] %v", r.err)
+			slog.Warn("Dropping coin", "ticker", cfg.Coins[i].Ticker, "err", r.err)
 			continue
 		}
 		profs = append(profs, r.prof)
@@ -121,60 +203,123 @@ func computeProfitability(cfg *Config, hashrate int) ([]CoinProfitability, error
 
 // printTable prints the profitability ranking table and historical averages.
 func printTable(profs []CoinProfitability, fiat string, currentTicker string, hist *History, hashrate int) {
-	now := time.Now().Format("2006-01-02 15:04:05")
+	avgs, mined := hist.Averages()
+	writeTable(styled(os.Stdout), time.Now(), profs, fiat, currentTicker, avgs, mined, hashrate)
+}
+
+// writeTable renders the ranking table for the given time, followed by the averages.
+func writeTable(w io.Writer, at time.Time, profs []CoinProfitability, fiat string, currentTicker string, avgs []CoinAverage, mined MinedAverage, hashrate int) {
+	now := at.Format("2006-01-02 15:04:05")
 	currency := strings.ToUpper(fiat)
 
-	fmt.Println()
-	fmt.Printf("  Profitability Report — %s  ⚡ %s\n", now, formatHashrate(float64(hashrate)))
-	fmt.Println(strings.Repeat("─", 84))
-	fmt.Printf("  %-4s  %-10s  %16s  %16s  %14s  %12s\n",
+	// The payout column only appears once a coin has a payout threshold.
+	payout := false
+	for _, p := range profs {
+		if p.PayoutThreshold > 0 {
+			payout = true
+		}
+	}
+	// Likewise the data column once a coin is shown from cached or last known data.
+	stale, failed := false, false
+	for _, p := range profs {
+		if p.StaleAge > 0 {
+			stale = true
+		}
+		if p.FailedAge > 0 {
+			failed = true
+		}
+	}
+	width := 84
+	if payout {
+		width += 12
+	}
+	if stale || failed {
+		width += 12
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  Profitability Report — %s  ⚡ %s\n", now, formatHashrate(float64(hashrate)))
+	fmt.Fprintln(w, strings.Repeat("─", width))
+	fmt.Fprintf(w, "  %-4s  %-10s  %16s  %16s  %14s  %12s",
 		"Rank", "Coin", "Daily (coin)", fmt.Sprintf("Daily (%s)", currency), "BTC/MH/Day", "Price (USD)")
-	fmt.Println(strings.Repeat("─", 84))
+	if payout {
+		fmt.Fprintf(w, "  %10s", "Payout in")
+	}
+	if stale || failed {
+		fmt.Fprintf(w, "  %10s", "Data")
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, strings.Repeat("─", width))
 
 	for i, p := range profs {
 		marker := "  "
 		if p.Ticker == currentTicker {
 			marker = "★ "
 		}
-		fmt.Printf("  %-4d  %s%-8s  %16.8f  %16.8f  %14.10f  %12.6f\n",
+		fmt.Fprintf(w, "  %-4d  %s%-8s  %16.8f  %16.8f  %14.10f  %12.6f",
 			i+1, marker, p.Ticker, p.DailyRevCoin, p.DailyRevenueFiat, p.BTCPerMHDay, p.CryptoRateUSD)
+		if d, ok := p.DaysToPayout(); ok {
+			fmt.Fprintf(w, "  %10s", formatDays(d))
+		} else if payout {
+			fmt.Fprintf(w, "  %10s", "—")
+		}
+		if st := p.dataStatus(); st != "" {
+			fmt.Fprintf(w, "  %10s", st)
+		} else if stale || failed {
+			fmt.Fprintf(w, "  %10s", "live")
+		}
+		fmt.Fprintln(w)
 	}
 
-	fmt.Println(strings.Repeat("─", 84))
-	fmt.Println("  ★ = currently mining")
+	fmt.Fprintln(w, strings.Repeat("─", width))
+	fmt.Fprintln(w, "  ★ = currently mining")
+	if stale {
+		fmt.Fprintln(w, "  stale = Kryptex failed, cached values of that age are shown")
+	}
+	if failed {
+		fmt.Fprintln(w, "  failed = no data this cycle, the last known values of that age are shown")
+	}
 
 	// Print averages if we have history
-	avgs, mined := hist.Averages()
-	if len(avgs) > 0 && avgs[0].Count > 1 {
-		fmt.Println()
-		fmt.Printf("  Averages (%d samples)\n", avgs[0].Count)
-		fmt.Println(strings.Repeat("─", 56))
-		fmt.Printf("  %-4s  %-10s  %16s  %14s\n", "Rank", "Coin", fmt.Sprintf("Avg (%s)", currency), "Avg BTC/MH/D")
-		fmt.Println(strings.Repeat("─", 56))
-		for i, a := range avgs {
-			marker := "  "
-			if a.Ticker == currentTicker {
-				marker = "★ "
-			}
-			fmt.Printf("  %-4d  %s%-8s  %16.8f  %14.10f\n",
-				i+1, marker, a.Ticker, a.AvgFiat, a.AvgBTCMH)
-		}
-		fmt.Println(strings.Repeat("─", 56))
-		if mined.Count > 0 {
-			fmt.Printf("  %s⛏  MINED AVG%s  %16.8f  %14.10f\n",
-				colorBold, colorReset, mined.AvgFiat, mined.AvgBTCMH)
-			fmt.Println(strings.Repeat("─", 56))
+	writeAverages(w, avgs, mined, fiat, currentTicker)
+
+	fmt.Fprintln(w)
+}
+
+// writeAverages renders the per-coin averages table and the mined average.
+// Nothing is written until there are at least two samples.
+func writeAverages(w io.Writer, avgs []CoinAverage, mined MinedAverage, fiat string, currentTicker string) {
+	if len(avgs) == 0 || avgs[0].Count <= 1 {
+		return
+	}
+	currency := strings.ToUpper(fiat)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  Averages (%d samples)\n", avgs[0].Count)
+	fmt.Fprintln(w, strings.Repeat("─", 56))
+	fmt.Fprintf(w, "  %-4s  %-10s  %16s  %14s\n", "Rank", "Coin", fmt.Sprintf("Avg (%s)", currency), "Avg BTC/MH/D")
+	fmt.Fprintln(w, strings.Repeat("─", 56))
+	for i, a := range avgs {
+		marker := "  "
+		if a.Ticker == currentTicker {
+			marker = "★ "
 		}
+		fmt.Fprintf(w, "  %-4d  %s%-8s  %16.8f  %14.10f\n",
+			i+1, marker, a.Ticker, a.AvgFiat, a.AvgBTCMH)
+	}
+	fmt.Fprintln(w, strings.Repeat("─", 56))
+	if mined.Count > 0 {
+		fmt.Fprintf(w, "  %s⛏  MINED AVG%s  %16.8f  %14.10f\n",
+			colorBold, colorReset, mined.AvgFiat, mined.AvgBTCMH)
+		fmt.Fprintln(w, strings.Repeat("─", 56))
 	}
-
-	fmt.Println()
 }
 
-// switchWorkers bulk-assigns all workers not already on targetProfileID to the new profile.
-func switchWorkers(cfg *Config, targetProfileID, targetTicker string) error {
+// switchWorkers bulk-assigns all workers not already on targetProfileID to the new profile
+// and returns how many were reassigned.
+func switchWorkers(cfg *Config, targetProfileID, targetTicker string) (int, error) {
 	workers, err := fetchAllWorkers(cfg.ProxyBaseURL, cfg.ProxyAPIKey, cfg.ProxyAlgorithm)
 	if err != nil {
-		return fmt.Errorf("fetch workers: %w", err)
+		return 0, fmt.Errorf("fetch workers: %w", err)
 	}
 
 	// Only switch workers that are NOT already on the target profile
@@ -189,12 +334,12 @@ func switchWorkers(cfg *Config, targetProfileID, targetTicker string) error {
 	}
 
 	if len(ids) == 0 {
-		return nil
+		return 0, nil
 	}
 
-	log.Printf("[SWITCH] Assigning %d/%d worker(s) to profile %s (%s)...\n", len(ids), len(workers), targetProfileID, targetTicker)
+	slog.Info("Assigning workers", "ticker", targetTicker, "profile_id", targetProfileID, "worker_count", len(ids), "total_workers", len(workers))
 	if err := bulkAssignWorkers(cfg.ProxyBaseURL, cfg.ProxyAPIKey, ids, targetProfileID); err != nil {
-		return fmt.Errorf("bulk assign: %w", err)
+		return 0, fmt.Errorf("bulk assign: %w", err)
 	}
-	return nil
+	return len(ids), nil
 }
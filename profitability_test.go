@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// kryptexStub serves /rates and /daily-revenue/{coin} from fixed values.
+// revenue is coins/day at 1 MH/s, scaled to the requested hashrate; missing
+// coins return 404.
+func kryptexStub(t *testing.T, fiat, crypto map[string]float64, revenue map[string]float64) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /rates", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, KryptexRates{Fiat: fiat, Crypto: crypto})
+	})
+	mux.HandleFunc("GET /daily-revenue/{coin}", func(w http.ResponseWriter, r *http.Request) {
+		rev, ok := revenue[r.PathValue("coin")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		hashrate, _ := strconv.ParseFloat(r.URL.Query().Get("hashrate"), 64)
+		fmt.Fprintf(w, "%g\n", rev*hashrate/1e6)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestComputeProfitability(t *testing.T) {
+	fiat := map[string]float64{"USD": 1, "EUR": 1.25}
+	crypto := map[string]float64{"BTC": 50000, "XMR": 200, "XTM": 0.002, "SAL": 0.05}
+	revenue := map[string]float64{"XMR": 0.001, "XTM_RX": 150, "SAL": 2}
+
+	tests := []struct {
+		name     string
+		fiat     string
+		hashrate int
+		coins    []CoinConfig
+		want     []CoinProfitability
+	}{
+		{
+			name:     "usd sorted by revenue",
+			fiat:     "USD",
+			hashrate: 100000,
+			coins: []CoinConfig{
+				{Ticker: "XMR", ProfileID: "p-xmr"},
+				{Ticker: "SAL", ProfileID: "p-sal"},
+			},
+			want: []CoinProfitability{
+				// 0.1 MH/s earns 0.0001 XMR × $200 = $0.02; in BTC 0.02/50000 = 4e-7 per 0.1 MH/s → 4e-6 per MH
+				{Ticker: "XMR", ProfileID: "p-xmr", DailyRevCoin: 0.0001, CryptoRateUSD: 200, DailyRevenueFiat: 0.02, BTCPerMHDay: 4e-6, FiatRate: 1},
+				{Ticker: "SAL", ProfileID: "p-sal", DailyRevCoin: 0.2, CryptoRateUSD: 0.05, DailyRevenueFiat: 0.01, BTCPerMHDay: 2e-6, FiatRate: 1},
+			},
+		},
+		{
+			name:     "eur conversion and revenue ticker",
+			fiat:     "eur",
+			hashrate: 1_000_000,
+			coins: []CoinConfig{
+				{Ticker: "XTM", RevenueTicker: "XTM_RX", ProfileID: "p-xtm"},
+				{Ticker: "XMR", ProfileID: "p-xmr"},
+			},
+			want: []CoinProfitability{
+				// 150 XTM × $0.002 = $0.30 → €0.24
+				{Ticker: "XTM", ProfileID: "p-xtm", DailyRevCoin: 150, CryptoRateUSD: 0.002, DailyRevenueFiat: 0.24, BTCPerMHDay: 6e-6, FiatRate: 1.25},
+				{Ticker: "XMR", ProfileID: "p-xmr", DailyRevCoin: 0.001, CryptoRateUSD: 200, DailyRevenueFiat: 0.16, BTCPerMHDay: 4e-6, FiatRate: 1.25},
+			},
+		},
+		{
+			name:     "coins without revenue or rate are dropped",
+			fiat:     "USD",
+			hashrate: 1_000_000,
+			coins: []CoinConfig{
+				{Ticker: "XMR", ProfileID: "p-xmr"},
+				{Ticker: "ZEPH", ProfileID: "p-zeph"},                       // no revenue
+				{Ticker: "XTM", ProfileID: "p-xtm"},                         // revenue ticker not set → 404
+				{Ticker: "DOGE", RevenueTicker: "SAL", ProfileID: "p-doge"}, // no crypto rate
+			},
+			want: []CoinProfitability{
+				{Ticker: "XMR", ProfileID: "p-xmr", DailyRevCoin: 0.001, CryptoRateUSD: 200, DailyRevenueFiat: 0.2, BTCPerMHDay: 4e-6, FiatRate: 1},
+			},
+		},
+	}
+
+	srv := kryptexStub(t, fiat, crypto, revenue)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{KryptexBaseURL: srv.URL, FiatCurrency: tt.fiat, Coins: tt.coins}
+			got, err := computeProfitability(cfg, tt.hashrate, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d coins, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				g := got[i]
+				if g.Ticker != w.Ticker || g.ProfileID != w.ProfileID {
+					t.Errorf("[%d] got %s/%s, want %s/%s", i, g.Ticker, g.ProfileID, w.Ticker, w.ProfileID)
+				}
+				for _, f := range []struct {
+					name      string
+					got, want float64
+				}{
+					{"DailyRevCoin", g.DailyRevCoin, w.DailyRevCoin},
+					{"CryptoRateUSD", g.CryptoRateUSD, w.CryptoRateUSD},
+					{"DailyRevenueFiat", g.DailyRevenueFiat, w.DailyRevenueFiat},
+					{"BTCPerMHDay", g.BTCPerMHDay, w.BTCPerMHDay},
+					{"FiatRate", g.FiatRate, w.FiatRate},
+				} {
+					if !approxEqual(f.got, f.want) {
+						t.Errorf("[%d] %s %s = %g, want %g", i, g.Ticker, f.name, f.got, f.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestComputeProfitabilityErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fiat     map[string]float64
+		crypto   map[string]float64
+		currency string
+		wantErr  string
+	}{
+		{"unknown fiat", map[string]float64{"USD": 1}, map[string]float64{"BTC": 1}, "JPY", "unknown fiat currency"},
+		{"no btc rate", map[string]float64{"USD": 1}, map[string]float64{"XMR": 1}, "USD", "BTC rate not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := kryptexStub(t, tt.fiat, tt.crypto, nil)
+			cfg := &Config{KryptexBaseURL: srv.URL, FiatCurrency: tt.currency, Coins: []CoinConfig{{Ticker: "XMR"}}}
+			_, err := computeProfitability(cfg, 1000, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatHashrate(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{0, "0 H/s"},
+		{999, "999 H/s"},
+		{1000, "1.00 KH/s"},
+		{8070, "8.07 KH/s"},
+		{150e6, "150.00 MH/s"},
+		{2.5e9, "2.50 GH/s"},
+		{1e12, "1.00 TH/s"},
+	}
+	for _, tt := range tests {
+		if got := formatHashrate(tt.in); got != tt.want {
+			t.Errorf("formatHashrate(%g) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteTableGolden(t *testing.T) {
+	profs := []CoinProfitability{
+		{Ticker: "XTM", DailyRevCoin: 227.10352962, CryptoRateUSD: 0.001136, DailyRevenueFiat: 0.30435865, BTCPerMHDay: 0.0004931422},
+		{Ticker: "SAL", DailyRevCoin: 5.51041249, CryptoRateUSD: 0.04011, DailyRevenueFiat: 0.26074753, BTCPerMHDay: 0.0004224805},
+		{Ticker: "XMR", DailyRevCoin: 0.00064817, CryptoRateUSD: 312.2, DailyRevenueFiat: 0.23873052, BTCPerMHDay: 0.0003868071},
+	}
+	avgs, mined := averagesOf(testSnapshots())
+	var buf bytes.Buffer
+	at := time.Date(2026, 2, 23, 18, 34, 45, 0, time.UTC)
+	writeTable(&buf, at, profs, "eur", "XTM", avgs, mined, 8070)
+	checkGolden(t, "table.golden", buf.Bytes())
+}
+
+func approxEqual(a, b float64) bool {
+	if a == b {
+		return true
+	}
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
+
+func TestWriteSummary(t *testing.T) {
+	profs := []CoinProfitability{
+		{Ticker: "XTM", DailyRevenueFiat: 0.30435865},
+		{Ticker: "SAL", DailyRevenueFiat: 0.26074753},
+	}
+	var buf bytes.Buffer
+	at := time.Date(2026, 2, 23, 18, 34, 45, 0, time.UTC)
+	writeSummary(&buf, at, profs, "eur", "SAL", 8070)
+	want := "2026-02-23 18:34:45  ⚡ 8.07 KH/s  XTM 0.30435865 | ★SAL 0.26074753 EUR/day\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteTablePayoutGolden(t *testing.T) {
+	profs := []CoinProfitability{
+		{Ticker: "XTM", DailyRevCoin: 227.10352962, CryptoRateUSD: 0.001136, DailyRevenueFiat: 0.30435865, BTCPerMHDay: 0.0004931422, PayoutThreshold: 1000, Unpaid: 100},
+		{Ticker: "SAL", DailyRevCoin: 5.51041249, CryptoRateUSD: 0.04011, DailyRevenueFiat: 0.26074753, BTCPerMHDay: 0.0004224805, PayoutThreshold: 5, Unpaid: 2},
+		{Ticker: "XMR", DailyRevCoin: 0.00064817, CryptoRateUSD: 312.2, DailyRevenueFiat: 0.23873052, BTCPerMHDay: 0.0003868071},
+	}
+	var buf bytes.Buffer
+	at := time.Date(2026, 2, 23, 18, 34, 45, 0, time.UTC)
+	writeTable(&buf, at, profs, "eur", "XTM", nil, MinedAverage{}, 8070)
+	checkGolden(t, "table_payout.golden", buf.Bytes())
+}
+
+func TestWriteTableStaleGolden(t *testing.T) {
+	profs := []CoinProfitability{
+		{Ticker: "XTM", DailyRevCoin: 227.10352962, CryptoRateUSD: 0.001136, DailyRevenueFiat: 0.30435865, BTCPerMHDay: 0.0004931422, StaleAge: 12 * time.Minute},
+		{Ticker: "SAL", DailyRevCoin: 5.51041249, CryptoRateUSD: 0.04011, DailyRevenueFiat: 0.26074753, BTCPerMHDay: 0.0004224805},
+		{Ticker: "XMR", DailyRevCoin: 0.00071207, CryptoRateUSD: 331.52, DailyRevenueFiat: 0.19716025, BTCPerMHDay: 0.0003194781, FailedAge: 25 * time.Minute},
+	}
+	var buf bytes.Buffer
+	at := time.Date(2026, 2, 23, 18, 34, 45, 0, time.UTC)
+	writeTable(&buf, at, profs, "eur", "XTM", nil, MinedAverage{}, 8070)
+	checkGolden(t, "table_stale.golden", buf.Bytes())
+
+	buf.Reset()
+	writeSummary(&buf, at, profs, "eur", "SAL", 8070)
+	want := "2026-02-23 18:34:45  ⚡ 8.07 KH/s  XTM 0.30435865 (stale 12m) | ★SAL 0.26074753 | XMR 0.19716025 (failed 25m) EUR/day\n"
+	if buf.String() != want {
+		t.Errorf("summary = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestJSONNumber(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"data":{"unpaid":"0.125","wallets":[{"balance":2.5}]}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path    string
+		want    float64
+		wantErr bool
+	}{
+		{"data.unpaid", 0.125, false},
+		{"data.wallets.0.balance", 2.5, false},
+		{"data.missing", 0, true},
+		{"data.wallets.3.balance", 0, true},
+		{"data", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := jsonNumber(doc, tt.path)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("jsonNumber(%q) = %v, %v; want %v, error %v", tt.path, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestComputeProfitabilityBalance(t *testing.T) {
+	kryptex := kryptexStub(t, map[string]float64{"USD": 1}, map[string]float64{"BTC": 50000, "XMR": 200, "SAL": 0.05},
+		map[string]float64{"XMR": 0.001, "SAL": 2})
+	pool := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"result":{"unpaid":0.05}}`)
+	}))
+	defer pool.Close()
+
+	cfg := &Config{
+		KryptexBaseURL: kryptex.URL,
+		FiatCurrency:   "USD",
+		Coins: []CoinConfig{
+			{Ticker: "XMR", ProfileID: "p-xmr", PayoutThreshold: 0.1, BalanceURL: pool.URL, BalanceField: "result.unpaid"},
+			{Ticker: "SAL", ProfileID: "p-sal", PayoutThreshold: 10, UnpaidBalance: 4},
+		},
+	}
+	profs, err := computeProfitability(cfg, 1_000_000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, p := range profs {
+		got[p.Ticker] = p.Unpaid
+	}
+	if got["XMR"] != 0.05 || got["SAL"] != 4 {
+		t.Errorf("unpaid balances = %v, want XMR 0.05 and SAL 4", got)
+	}
+}
+
+func TestComputeProfitabilityAccount(t *testing.T) {
+	kryptex := kryptexStub(t, map[string]float64{"USD": 1}, map[string]float64{"BTC": 50000, "XMR": 200, "SAL": 0.05},
+		map[string]float64{"XMR": 0.001, "SAL": 2})
+	stats := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xmr/4Abc" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"unpaid":"0.03","paid":1.5,"hashrate":950000}`)
+	}))
+	defer stats.Close()
+
+	cfg := &Config{
+		KryptexBaseURL:  kryptex.URL,
+		KryptexStatsURL: stats.URL + "/{coin}/{wallet}",
+		FiatCurrency:    "USD",
+		Coins: []CoinConfig{
+			{Ticker: "XMR", ProfileID: "p-xmr", PayoutThreshold: 0.1, Wallet: "4Abc"},
+			{Ticker: "SAL", ProfileID: "p-sal", PayoutThreshold: 10, UnpaidBalance: 4, Wallet: "missing"},
+		},
+	}
+	profs, err := computeProfitability(cfg, 1_000_000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]CoinProfitability{}
+	for _, p := range profs {
+		got[p.Ticker] = p
+	}
+	want := MinerStats{Unpaid: 0.03, Paid: 1.5, Hashrate: 950000}
+	if a := got["XMR"].Account; a == nil || *a != want {
+		t.Errorf("XMR account = %+v, want %+v", a, want)
+	}
+	if got["XMR"].Unpaid != 0.03 {
+		t.Errorf("XMR unpaid = %v, want the account balance 0.03", got["XMR"].Unpaid)
+	}
+	// A failed stats request falls back to unpaid_balance and keeps the coin
+	if got["SAL"].Account != nil || got["SAL"].Unpaid != 4 {
+		t.Errorf("SAL = %+v, want no account and unpaid 4", got["SAL"])
+	}
+}
+
+func TestFetchDailyRevenueSendsHashrate(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		fmt.Fprint(w, "0.00123")
+	}))
+	defer srv.Close()
+	rev, err := fetchDailyRevenue(srv.URL, "XMR", 8070)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The pool's figure is used as is: fees and rounding make it non-linear
+	if query != "hashrate=8070" || rev != 0.00123 {
+		t.Errorf("query %q returned %g, want hashrate=8070 and 0.00123", query, rev)
+	}
+}
@@ -12,6 +12,19 @@ type Worker struct {
 	Hashrate  uint64 `json:"hashrate"`
 }
 
+// Profile is an Ultimate Proxy profile (pool + wallet target workers can be assigned to).
+type Profile struct {
+	ID        string `json:"_id"`
+	Name      string `json:"name"`
+	Algorithm string `json:"algorithm"`
+	IsDefault bool   `json:"is_default"`
+}
+
+type ProfilesResponse struct {
+	Data       []Profile  `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
 type WorkersResponse struct {
 	Data       []Worker   `json:"data"`
 	Pagination Pagination `json:"pagination"`
@@ -42,6 +55,10 @@ type HashrateStats struct {
 	PeakHashrate float64 `json:"peak_hashrate"`
 }
 
+// hashrateRanges are the timeRange values GET /v1/workers/hashrate accepts,
+// with the number of hours each covers.
+var hashrateRanges = map[string]int{"1h": 1, "6h": 6, "24h": 24, "7d": 168}
+
 func proxyHeaders(apiKey string) map[string]string {
 	return map[string]string{"X-API-Key": apiKey}
 }
@@ -64,6 +81,24 @@ func fetchAllWorkers(baseURL, apiKey, algorithm string) ([]Worker, error) {
 	return all, nil
 }
 
+func fetchAllProfiles(baseURL, apiKey string) ([]Profile, error) {
+	var all []Profile
+	page := 1
+	for {
+		url := fmt.Sprintf("%s/v1/profiles?page=%d&limit=100", baseURL, page)
+		var resp ProfilesResponse
+		if err := fetchJSON(url, proxyHeaders(apiKey), &resp); err != nil {
+			return nil, fmt.Errorf("fetch profiles page %d: %w", page, err)
+		}
+		all = append(all, resp.Data...)
+		if page >= resp.Pagination.TotalPages {
+			break
+		}
+		page++
+	}
+	return all, nil
+}
+
 func bulkAssignWorkers(baseURL, apiKey string, workerIDs []string, profileID string) error {
 	url := baseURL + "/v1/workers/bulk-assign"
 	payload := BulkAssignRequest{
@@ -78,9 +113,10 @@ func setDefaultProfile(baseURL, apiKey, profileID string) error {
 	return postJSON(url, proxyHeaders(apiKey), nil)
 }
 
-// fetchHashrate calls GET /v1/workers/hashrate and returns the 1h average and peak hashrate (H/s).
-func fetchHashrate(baseURL, apiKey, algorithm string) (avg float64, peak float64, err error) {
-	url := fmt.Sprintf("%s/v1/workers/hashrate?algorithm=%s&timeRange=1h", baseURL, algorithm)
+// fetchHashrate calls GET /v1/workers/hashrate and returns the average and peak
+// hashrate (H/s) over timeRange (e.g. 1h).
+func fetchHashrate(baseURL, apiKey, algorithm, timeRange string) (avg float64, peak float64, err error) {
+	url := fmt.Sprintf("%s/v1/workers/hashrate?algorithm=%s&timeRange=%s", baseURL, algorithm, timeRange)
 	var resp HashrateResponse
 	if err := fetchJSON(url, proxyHeaders(apiKey), &resp); err != nil {
 		return 0, 0, fmt.Errorf("fetch hashrate: %w", err)
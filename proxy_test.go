@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFetchAllWorkersPagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     int
+		wantPages int
+	}{
+		{name: "empty", total: 0, wantPages: 1},
+		{name: "single page", total: 40, wantPages: 1},
+		{name: "exact page", total: 100, wantPages: 1},
+		{name: "three pages", total: 250, wantPages: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages []int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-API-Key") != "key" {
+					t.Errorf("missing API key header")
+				}
+				if got := r.URL.Query().Get("algorithm"); got != "randomx" {
+					t.Errorf("algorithm = %q", got)
+				}
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+				pages = append(pages, page)
+				var data []Worker
+				for i := (page - 1) * limit; i < page*limit && i < tt.total; i++ {
+					data = append(data, Worker{ID: fmt.Sprintf("w%03d", i)})
+				}
+				writeJSON(w, WorkersResponse{
+					Data:       data,
+					Pagination: Pagination{Page: page, Limit: limit, Total: tt.total, TotalPages: (tt.total + limit - 1) / limit},
+				})
+			}))
+			defer srv.Close()
+
+			workers, err := fetchAllWorkers(srv.URL, "key", "randomx")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(workers) != tt.total {
+				t.Errorf("got %d workers, want %d", len(workers), tt.total)
+			}
+			if len(pages) != tt.wantPages {
+				t.Errorf("fetched pages %v, want %d page(s)", pages, tt.wantPages)
+			}
+			for i, w := range workers {
+				if w.ID != fmt.Sprintf("w%03d", i) {
+					t.Fatalf("worker %d = %s, out of order", i, w.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchAllWorkersPageError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		writeJSON(w, WorkersResponse{Data: []Worker{{ID: "a"}}, Pagination: Pagination{Page: 1, TotalPages: 2}})
+	}))
+	defer srv.Close()
+	_, err := fetchAllWorkers(srv.URL, "key", "randomx")
+	if err == nil || !strings.Contains(err.Error(), "page 2") || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("err = %v, want page 2 / 429", err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"time"
+)
+
+// sameHTTPSettings reports whether a and b build the same HTTP client, so a
+// reload can keep the current one along with its cache and rate limits.
+func sameHTTPSettings(a, b *Config) bool {
+	return a.CABundle == b.CABundle && a.HTTPProxy == b.HTTPProxy &&
+		maps.Equal(a.HTTPCache, b.HTTPCache) && a.HTTPCacheDir == b.HTTPCacheDir &&
+		a.HTTPMaxStale == b.HTTPMaxStale && a.HTTPRateLimit == b.HTTPRateLimit
+}
+
+// sameStoreSettings reports whether a and b open the same history store.
+func sameStoreSettings(a, b *Config) bool {
+	return a.HistoryDB == b.HistoryDB && a.RawRetention == b.RawRetention &&
+		a.HourlyRetention == b.HourlyRetention && a.DailyRetention == b.DailyRetention
+}
+
+// watchConfigFile polls path every interval and signals on changed when its
+// modification time or size differs from the last observed value.
+func watchConfigFile(path string, interval time.Duration, changed chan<- struct{}) {
+	var lastMod time.Time
+	var lastSize int64
+	if fi, err := os.Stat(path); err == nil {
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue // file may be mid-replace; try again next tick
+		}
+		if fi.ModTime().Equal(lastMod) && fi.Size() == lastSize {
+			continue
+		}
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
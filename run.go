@@ -0,0 +1,372 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// cmdRun implements the `run` subcommand: the profit-switching daemon.
+func cmdRun(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	dryRun := fs.Bool("dry-run", false, "Display profitability table without switching")
+	once := fs.Bool("once", false, "Run a single cycle and exit")
+	strict := fs.Bool("strict", false, "Reject unknown keys and invalid coin entries in the config")
+	watch := fs.Bool("watch", false, "Reload config automatically when the file changes (SIGHUP always reloads)")
+	logFormat := fs.String("log-format", "text", "Log format: text or json")
+	logLevel := fs.String("log-level", "info", "Log level: debug, info, warn or error")
+	output := fs.String("output", outputFull, "Per-cycle report: full (table and chart), compact (one line), tui (interactive screen) or none")
+	quiet := fs.Bool("quiet", false, "Same as -output none; the ranking is logged instead")
+	color := fs.String("color", "auto", "Color output: auto (TTY and no NO_COLOR), always or never")
+	ascii := fs.Bool("ascii", false, "Use ASCII instead of box-drawing characters")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+
+	if err := setupLogging(os.Stderr, *logFormat, *logLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if err := configureOutput(*color, *ascii); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *quiet {
+		*output = outputNone
+	}
+	switch *output {
+	case outputFull, outputCompact, outputTUI, outputNone:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -output %q (use full, compact, tui or none)\n", *output)
+		return 2
+	}
+	if *output == outputTUI && *once {
+		fmt.Fprintln(os.Stderr, "-output tui cannot be combined with -once")
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath, *strict)
+	if err != nil {
+		fatal("Failed to load config", "err", err)
+	}
+	httpClient = cfg.httpClient
+
+	slog.Info("Loaded config", "coins", len(cfg.Coins), "interval", cfg.Interval, "fiat", cfg.FiatCurrency)
+	if *dryRun {
+		slog.Info("Dry-run mode: will NOT switch workers")
+	}
+
+	dec := NewDecider(policyFromConfig(cfg))
+	// 24h of history: 86400s / interval. Longer chart windows read the store.
+	histSize := (86400 / cfg.Interval) + 1
+	hist := NewHistory(histSize)
+	guard := NewAnomalyGuard(anomalyPolicyFromConfig(cfg), hist)
+	known := NewLastKnown(cfg.lastKnownAge)
+
+	// Load persisted history
+	if err := hist.Load(cfg.HistoryFile); err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Failed to load history", "err", err)
+		}
+	} else {
+		snaps := hist.All()
+		if len(snaps) > 0 {
+			dec.Prime(snaps)
+			known.Prime(snaps, cfg.Coins)
+			slog.Info("Restored history", "snapshots", len(snaps), "path", cfg.HistoryFile, "ticker", dec.Current)
+		}
+	}
+
+	// Long-term store (optional)
+	var store *Store
+	if cfg.HistoryDB != "" {
+		if store, err = OpenStore(cfg.HistoryDB, cfg.RawRetention, cfg.HourlyRetention, cfg.DailyRetention); err != nil {
+			fatal("Failed to open history store", "err", err)
+		}
+	}
+
+	// Graceful shutdown
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	// Config reload: SIGHUP, and file changes when -watch is set
+	reload := make(chan struct{}, 1)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			select {
+			case reload <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	if *watch {
+		go watchConfigFile(*configPath, 5*time.Second, reload)
+	}
+
+	// Interactive screen: logs go to its event pane from here on
+	var ui *tui
+	if *output == outputTUI {
+		ui = newTUI(cfg)
+		if err := ui.Start(); err != nil {
+			fatal("Failed to start TUI", "err", err)
+		}
+		defer ui.Stop()
+		setupLogging(ui.LogWriter(), *logFormat, *logLevel)
+		ui.Draw()
+	}
+
+	ticker := time.NewTicker(time.Duration(cfg.Interval) * time.Second)
+	defer ticker.Stop()
+
+	run := func() {
+		hashrate, hashrateSource := currentHashrate(cfg, hist)
+		if hashrate == 0 {
+			slog.Error("No hashrate available, skipping cycle", "fallback", strings.Join(cfg.HashrateFallback, ","))
+			return
+		}
+
+		profs, err := computeProfitability(cfg, hashrate, guard)
+		if err != nil {
+			slog.Error("Failed to compute profitability", "err", err)
+			return
+		}
+		profs = known.Fill(time.Now(), profs, cfg.Coins, hashrate)
+		if len(profs) == 0 {
+			slog.Warn("No profitability data available")
+			return
+		}
+
+		if *output == outputFull {
+			printTable(profs, cfg.FiatCurrency, dec.Current, hist, hashrate)
+		}
+		logRanking(profs, cfg.FiatCurrency, hashrate, *output == outputNone)
+
+		values := make(map[string]float64, len(profs))
+		byTicker := make(map[string]CoinProfitability, len(profs))
+		for _, p := range profs {
+			values[p.Ticker] = p.DailyRevenueFiat
+			byTicker[p.Ticker] = p
+		}
+		logPayout(profs)
+		dec.DaysToPayout = daysToPayout(profs)
+		if _, ok := cfg.coin(dec.Current); dec.Current != "" && !ok {
+			slog.Warn("Current coin is no longer configured, choosing again", "ticker", dec.Current)
+			dec.Current = ""
+		}
+		now := time.Now()
+		d := dec.Decide(now, values)
+		target, ok := byTicker[d.Ticker]
+		if !ok {
+			// Staying on a coin without data: its profile is still the configured one
+			c, _ := cfg.coin(d.Ticker)
+			target = CoinProfitability{Ticker: c.Ticker, ProfileID: c.ProfileID, FiatRate: profs[0].FiatRate}
+		}
+		switched := false
+		reason := d.Reason
+		workersSwitched := 0
+
+		// Always ensure the target coin is the default profile (for new miners connecting)
+		if !*dryRun {
+			if err := setDefaultProfile(cfg.ProxyBaseURL, cfg.ProxyAPIKey, target.ProfileID); err != nil {
+				slog.Warn("Failed to set default profile", "profile_id", target.ProfileID, "err", err)
+			}
+		}
+
+		switch {
+		case d.Reason == reasonThreshold:
+			slog.Info("Staying on current coin: gain below threshold", "ticker", dec.Current, "best", d.Best, "gain_pct", round2(d.GainPct), "threshold_pct", dec.Policy.Threshold)
+		case d.Reason == reasonDwell:
+			slog.Info("Staying on current coin: minimum dwell not elapsed", "ticker", dec.Current, "best", d.Best, "gain_pct", round2(d.GainPct), "min_dwell", dec.Policy.MinDwell.String())
+		case d.Reason == reasonPaused:
+			slog.Info("Staying on current coin: switching paused", "ticker", dec.Current, "best", d.Best)
+		case d.Reason == reasonMissing:
+			slog.Warn("Staying on current coin: no data for it this cycle", "ticker", dec.Current, "best", d.Best)
+		case d.Switch:
+			if dec.Current == "" {
+				slog.Info("Starting with most profitable coin", "ticker", d.Ticker, "profile_id", target.ProfileID)
+			} else if d.Reason == reasonPayout {
+				slog.Info("Switching for payout", "from", dec.Current, "ticker", d.Ticker, "profile_id", target.ProfileID, "days_to_payout", round2(dec.DaysToPayout[d.Ticker]))
+			} else if d.GainPct > 0 {
+				slog.Info("Switching", "from", dec.Current, "ticker", d.Ticker, "profile_id", target.ProfileID, "gain_pct", round2(d.GainPct))
+			} else {
+				slog.Info("Switching", "from", dec.Current, "ticker", d.Ticker, "profile_id", target.ProfileID)
+			}
+
+			var err error
+			if !*dryRun {
+				workersSwitched, err = switchWorkers(cfg, target.ProfileID, target.Ticker)
+			}
+			if err != nil {
+				slog.Error("Switch failed", "ticker", d.Ticker, "profile_id", target.ProfileID, "err", err)
+				reason = reasonError
+			} else {
+				switched = dec.Current != "" // not a switch on first run
+				dec.Commit(d.Ticker, now)
+			}
+		}
+
+		// Record snapshot for chart
+		coins := make(map[string]float64, len(profs))
+		coinsBTC := make(map[string]float64, len(profs))
+		prices := make(map[string]float64, len(profs))
+		coinRev := make(map[string]float64, len(profs))
+		var earned, poolHR map[string]float64
+		for _, p := range profs {
+			if p.FailedAge > 0 {
+				continue // history only records values actually fetched
+			}
+			coins[p.Ticker] = p.DailyRevenueFiat
+			coinsBTC[p.Ticker] = p.BTCPerMHDay
+			prices[p.Ticker] = p.CryptoRateUSD
+			coinRev[p.Ticker] = p.DailyRevCoin
+			if p.Account != nil {
+				if earned == nil {
+					earned, poolHR = map[string]float64{}, map[string]float64{}
+				}
+				earned[p.Ticker] = p.Account.Earned()
+				poolHR[p.Ticker] = p.Account.Hashrate
+			}
+		}
+		snap := Snapshot{
+			Time:            now,
+			Coins:           coins,
+			CoinsBTC:        coinsBTC,
+			Mining:          dec.Current,
+			Switched:        switched,
+			Hashrate:        float64(hashrate),
+			HashrateSource:  hashrateSource,
+			Prices:          prices,
+			CoinRevenue:     coinRev,
+			FiatRate:        target.FiatRate,
+			WorkersSwitched: workersSwitched,
+			Reason:          reason,
+			Earned:          earned,
+			PoolHashrate:    poolHR,
+		}
+		hist.Add(snap)
+		if store != nil {
+			if err := store.Append(snap); err != nil {
+				slog.Warn("Failed to append to history store", "err", err)
+			}
+		}
+
+		// Persist history to disk
+		if err := hist.Save(cfg.HistoryFile); err != nil {
+			slog.Warn("Failed to save history", "path", cfg.HistoryFile, "err", err)
+		}
+
+		if cfg.ChartImage != "" {
+			// A fresh install has no chart until the second snapshot
+			if snaps := chartSnapshots(hist, store, cfg.chartWindow, now); len(snaps) < 2 {
+				slog.Debug("Not enough history for the chart image yet", "path", cfg.ChartImage, "snapshots", len(snaps))
+			} else if err := writeChartImage(cfg.ChartImage, snaps, cfg.FiatCurrency, cfg.chartOptions(), cfg.ChartImageWidth, cfg.ChartImageHeight); err != nil {
+				slog.Warn("Failed to write chart image", "path", cfg.ChartImage, "err", err)
+			}
+		}
+
+		switch *output {
+		case outputFull:
+			printChart(chartSnapshots(hist, store, cfg.chartWindow, now), cfg.FiatCurrency, cfg.chartOptions())
+		case outputCompact:
+			printSummary(profs, cfg.FiatCurrency, dec.Current, hashrate)
+		case outputTUI:
+			if workers, err := fetchAllWorkers(cfg.ProxyBaseURL, cfg.ProxyAPIKey, cfg.ProxyAlgorithm); err != nil {
+				slog.Warn("Failed to fetch workers", "err", err)
+			} else {
+				ui.SetWorkers(workers)
+			}
+			ui.Update(now, profs, dec.Current, hashrate, hist)
+		}
+	}
+
+	// First run
+	run()
+
+	if *once {
+		return 0
+	}
+
+	for {
+		if ui != nil {
+			ui.Draw()
+		}
+		select {
+		case <-ticker.C:
+			run()
+		case <-reload:
+			newCfg, err := loadConfig(*configPath, *strict)
+			if err != nil {
+				slog.Error("Config reload failed, keeping current config", "err", err)
+				continue
+			}
+			if newCfg.Interval != cfg.Interval {
+				ticker.Reset(time.Duration(newCfg.Interval) * time.Second)
+				hist.Resize((86400 / newCfg.Interval) + 1)
+			}
+			switch {
+			case sameStoreSettings(newCfg, cfg):
+			case newCfg.HistoryDB == "":
+				store = nil
+			default:
+				s, err := OpenStore(newCfg.HistoryDB, newCfg.RawRetention, newCfg.HourlyRetention, newCfg.DailyRetention)
+				if err != nil {
+					slog.Error("Config reload failed, keeping current config", "err", err)
+					continue
+				}
+				store = s
+			}
+			// Keep the client, and with it the response cache and rate limiter,
+			// unless a setting it is built from changed
+			if sameHTTPSettings(newCfg, cfg) {
+				newCfg.httpClient = cfg.httpClient
+			}
+			cfg = newCfg
+			dec.Policy = policyFromConfig(cfg)
+			guard.Policy = anomalyPolicyFromConfig(cfg)
+			known.MaxAge = cfg.lastKnownAge
+			httpClient = cfg.httpClient
+			if ui != nil {
+				ui.SetConfig(cfg)
+			}
+			slog.Info("Reloaded config", "coins", len(cfg.Coins), "interval", cfg.Interval, "fiat", cfg.FiatCurrency)
+		case key := <-ui.Keys():
+			switch ui.HandleKey(key, dec) {
+			case tuiQuit:
+				slog.Info("Shutting down")
+				return 0
+			case tuiRefresh:
+				run()
+			}
+		case <-ui.Resized():
+		case <-stop:
+			slog.Info("Shutting down")
+			return 0
+		}
+	}
+}
+
+// chartSnapshots returns the history to plot: the in-memory 24h, or the
+// long-term store when the chart window is longer and a store is configured.
+func chartSnapshots(hist *History, store *Store, window time.Duration, now time.Time) []Snapshot {
+	if store == nil || window <= 24*time.Hour {
+		return hist.All()
+	}
+	snaps, err := store.Query(now.Add(-window), now.Add(time.Second))
+	if err != nil {
+		slog.Warn("Failed to read history store for the chart", "err", err)
+		return hist.All()
+	}
+	return snaps
+}
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SimScript describes the simulated market and farm. Prices and revenues are
+// paths indexed by tick; the simulator advances one tick per GET /rates call,
+// i.e. once per daemon cycle.
+type SimScript struct {
+	APIKey    string             `yaml:"api_key"`   // required X-API-Key (empty = any)
+	Algorithm string             `yaml:"algorithm"` // worker algorithm
+	Workers   int                `yaml:"workers"`
+	Hashrate  float64            `yaml:"hashrate"`     // per worker, H/s
+	BTCPrice  float64            `yaml:"btc_price"`    // USD
+	TickSecs  int                `yaml:"tick_seconds"` // time one tick stands for when crediting pool earnings (default: -interval)
+	Fiat      map[string]float64 `yaml:"fiat"`         // USD per unit of each fiat currency
+	Seed      int64              `yaml:"seed"`
+	Coins     []SimCoin          `yaml:"coins"`
+	Faults    []SimFault         `yaml:"faults"`
+}
+
+// SimCoin is one coin's price and revenue path. A fixed path (Prices,
+// RevenuePerMH) holds its last value once exhausted; otherwise the value
+// follows a seeded random walk from Start with the given Volatility per tick.
+type SimCoin struct {
+	Ticker        string    `yaml:"ticker"`
+	RevenueTicker string    `yaml:"revenue_ticker"`
+	ProfileID     string    `yaml:"profile_id"`
+	Prices        []float64 `yaml:"prices"`         // USD per coin, by tick
+	RevenuePerMH  []float64 `yaml:"revenue_per_mh"` // coins/day at 1 MH/s, by tick
+	Price         SimWalk   `yaml:"price_walk"`
+	Revenue       SimWalk   `yaml:"revenue_walk"`
+
+	Wallet          string  `yaml:"wallet"`           // written to the config to enable pool stats
+	Efficiency      float64 `yaml:"efficiency"`       // share of the estimate the pool credits (0 = 1)
+	PayoutThreshold float64 `yaml:"payout_threshold"` // unpaid balance moves to paid at this amount (0 = never)
+	StaleFrom       int     `yaml:"stale_from"`       // Kryptex /rates repeats the price of this tick from then on (0 = never)
+}
+
+type SimWalk struct {
+	Start      float64 `yaml:"start"`
+	Volatility float64 `yaml:"volatility"` // stddev of the per-tick relative change
+}
+
+// SimFault injects a failure into requests whose path contains Path, during
+// ticks From..To (inclusive; To 0 = forever) and with probability Rate
+// (0 = always).
+type SimFault struct {
+	Path   string  `yaml:"path"`
+	From   int     `yaml:"from"`
+	To     int     `yaml:"to"`
+	Rate   float64 `yaml:"rate"`
+	Status int     `yaml:"status"` // HTTP status to return (0 = keep the normal response)
+	Body   string  `yaml:"body"`   // body to return instead (e.g. malformed JSON)
+	Delay  string  `yaml:"delay"`  // added latency, e.g. "20s" to trigger client timeouts
+}
+
+// Simulator serves mock Kryptex (/kryptex) and Ultimate Proxy (/proxy) APIs.
+type Simulator struct {
+	mu       sync.Mutex
+	script   SimScript
+	tick     int
+	rng      *rand.Rand
+	series   map[string][]float64 // "price:XMR" / "rev:XMR" -> walk values by tick
+	workers  []Worker
+	profiles []Profile
+	defaultP string
+	switches int
+	unpaid   map[string]float64 // ticker -> simulated pool balance
+	paid     map[string]float64
+}
+
+func NewSimulator(s SimScript) *Simulator {
+	if s.Algorithm == "" {
+		s.Algorithm = "randomx"
+	}
+	if s.Workers <= 0 {
+		s.Workers = 4
+	}
+	if s.Hashrate <= 0 {
+		s.Hashrate = 10000
+	}
+	if s.BTCPrice <= 0 {
+		s.BTCPrice = 60000
+	}
+	if len(s.Fiat) == 0 {
+		s.Fiat = map[string]float64{"USD": 1, "EUR": 1.08}
+	}
+	if s.TickSecs <= 0 {
+		s.TickSecs = 300
+	}
+	sim := &Simulator{
+		script: s,
+		rng:    rand.New(rand.NewSource(s.Seed)),
+		series: make(map[string][]float64),
+		unpaid: make(map[string]float64),
+		paid:   make(map[string]float64),
+	}
+	for i := range s.Coins {
+		c := &sim.script.Coins[i]
+		c.Ticker = strings.ToUpper(c.Ticker)
+		if c.ProfileID == "" {
+			c.ProfileID = "sim-" + strings.ToLower(c.Ticker)
+		}
+		if c.Efficiency <= 0 {
+			c.Efficiency = 1
+		}
+		sim.profiles = append(sim.profiles, Profile{ID: c.ProfileID, Name: c.Ticker + " (simulated)", Algorithm: s.Algorithm})
+	}
+	for i := 0; i < s.Workers; i++ {
+		w := Worker{
+			ID:        fmt.Sprintf("sim-worker-%02d", i+1),
+			Name:      fmt.Sprintf("rig%02d", i+1),
+			Status:    "online",
+			Algorithm: s.Algorithm,
+			Hashrate:  uint64(s.Hashrate),
+		}
+		if len(sim.profiles) > 0 {
+			w.ProfileID = sim.profiles[0].ID
+		}
+		sim.workers = append(sim.workers, w)
+	}
+	return sim
+}
+
+// defaultSimScript is used when no script is given: three coins whose
+// profitability crosses over regularly, two of them credited below estimate.
+func defaultSimScript() SimScript {
+	return SimScript{
+		Seed: 1,
+		Coins: []SimCoin{
+			{Ticker: "XMR", Price: SimWalk{Start: 150, Volatility: 0.03}, Revenue: SimWalk{Start: 0.0009, Volatility: 0.01}, Wallet: "sim-wallet", Efficiency: 0.97},
+			{Ticker: "XTM", RevenueTicker: "XTM_RX", Price: SimWalk{Start: 0.0011, Volatility: 0.05}, Revenue: SimWalk{Start: 120, Volatility: 0.01}, Wallet: "sim-wallet", Efficiency: 0.9},
+			{Ticker: "SAL", Price: SimWalk{Start: 0.04, Volatility: 0.05}, Revenue: SimWalk{Start: 3.4, Volatility: 0.01}, Wallet: "sim-wallet"},
+		},
+	}
+}
+
+// Handler returns the HTTP handler for both mock APIs.
+func (sim *Simulator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /kryptex/rates", sim.handleRates)
+	mux.HandleFunc("GET /kryptex/daily-revenue/{coin}", sim.handleRevenue)
+	mux.HandleFunc("GET /kryptex/miner-stats/{coin}/{wallet}", sim.handleMinerStats)
+	mux.HandleFunc("GET /exchange/ticker/{coin}", sim.handleExchangeTicker)
+	mux.HandleFunc("GET /coingecko/simple/price", sim.handleCoinGecko)
+	mux.HandleFunc("GET /proxy/v1/workers", sim.auth(sim.handleWorkers))
+	mux.HandleFunc("GET /proxy/v1/workers/hashrate", sim.auth(sim.handleHashrate))
+	mux.HandleFunc("POST /proxy/v1/workers/bulk-assign", sim.auth(sim.handleBulkAssign))
+	mux.HandleFunc("GET /proxy/v1/profiles", sim.auth(sim.handleProfiles))
+	mux.HandleFunc("POST /proxy/v1/profiles/{id}/default", sim.auth(sim.handleDefault))
+	return sim.faults(mux)
+}
+
+func (sim *Simulator) faults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sim.mu.Lock()
+		tick := sim.tick
+		var hit *SimFault
+		for i := range sim.script.Faults {
+			f := &sim.script.Faults[i]
+			if !strings.Contains(r.URL.Path, f.Path) || tick < f.From || (f.To > 0 && tick > f.To) {
+				continue
+			}
+			if f.Rate > 0 && sim.rng.Float64() >= f.Rate {
+				continue
+			}
+			hit = f
+			break
+		}
+		sim.mu.Unlock()
+		if hit == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		slog.Info("Injecting fault", "tick", tick, "path", r.URL.Path)
+		if d, err := time.ParseDuration(hit.Delay); err == nil {
+			select {
+			case <-time.After(d):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if hit.Status == 0 && hit.Body == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		status := hit.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, hit.Body)
+	})
+}
+
+func (sim *Simulator) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if sim.script.APIKey != "" && r.Header.Get("X-API-Key") != sim.script.APIKey {
+			http.Error(w, `{"error":"invalid API key"}`, http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (sim *Simulator) handleRates(w http.ResponseWriter, r *http.Request) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if sim.tick > 0 {
+		sim.credit()
+	}
+	sim.tick++
+	crypto := map[string]float64{"BTC": sim.script.BTCPrice}
+	for _, c := range sim.script.Coins {
+		crypto[c.Ticker] = sim.value(c, "price", c.Prices, c.Price)
+		if c.StaleFrom > 0 && sim.tick > c.StaleFrom {
+			crypto[c.Ticker] = sim.valueAt(c, "price", c.Prices, c.Price, c.StaleFrom)
+		}
+	}
+	writeJSON(w, KryptexRates{Fiat: sim.script.Fiat, Crypto: crypto})
+}
+
+// handleExchangeTicker serves the live price Binance-style, quoted:
+// {"symbol":"XMRUSDT","price":"150.12"}.
+func (sim *Simulator) handleExchangeTicker(w http.ResponseWriter, r *http.Request) {
+	coin := strings.ToUpper(r.PathValue("coin"))
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if coin == "BTC" {
+		writeJSON(w, map[string]string{"symbol": "BTCUSDT", "price": strconv.FormatFloat(sim.script.BTCPrice, 'f', -1, 64)})
+		return
+	}
+	for _, c := range sim.script.Coins {
+		if c.Ticker == coin {
+			price := sim.value(c, "price", c.Prices, c.Price)
+			writeJSON(w, map[string]string{"symbol": coin + "USDT", "price": strconv.FormatFloat(price, 'f', -1, 64)})
+			return
+		}
+	}
+	http.Error(w, "unknown symbol", http.StatusNotFound)
+}
+
+// handleCoinGecko serves the live prices CoinGecko-style; coin ids are the
+// lower-case tickers.
+func (sim *Simulator) handleCoinGecko(w http.ResponseWriter, r *http.Request) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	out := map[string]map[string]float64{}
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id == "btc" {
+			out[id] = map[string]float64{"usd": sim.script.BTCPrice}
+		}
+		for _, c := range sim.script.Coins {
+			if strings.EqualFold(c.Ticker, id) {
+				out[id] = map[string]float64{"usd": sim.value(c, "price", c.Prices, c.Price)}
+			}
+		}
+	}
+	writeJSON(w, out)
+}
+
+func (sim *Simulator) handleRevenue(w http.ResponseWriter, r *http.Request) {
+	hashrate, err := strconv.ParseFloat(r.URL.Query().Get("hashrate"), 64)
+	if err != nil || hashrate <= 0 {
+		http.Error(w, "invalid hashrate", http.StatusBadRequest)
+		return
+	}
+	coin := strings.ToUpper(r.PathValue("coin"))
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	for _, c := range sim.script.Coins {
+		rev := c.RevenueTicker
+		if rev == "" {
+			rev = c.Ticker
+		}
+		if strings.EqualFold(rev, coin) {
+			perMH := sim.value(c, "rev", c.RevenuePerMH, c.Revenue)
+			fmt.Fprintf(w, "%.12f", perMH*hashrate/1e6)
+			return
+		}
+	}
+	http.Error(w, "unknown coin", http.StatusNotFound)
+}
+
+// credit pays every coin's pool balance for the tick that just ended, at the
+// revenue of that tick for the hashrate assigned to the coin's profile.
+func (sim *Simulator) credit() {
+	for _, c := range sim.script.Coins {
+		hashrate := sim.profileHashrate(c.ProfileID)
+		if hashrate == 0 {
+			continue
+		}
+		perMH := sim.value(c, "rev", c.RevenuePerMH, c.Revenue)
+		sim.unpaid[c.Ticker] += perMH * hashrate / 1e6 * float64(sim.script.TickSecs) / 86400 * c.Efficiency
+		if c.PayoutThreshold > 0 && sim.unpaid[c.Ticker] >= c.PayoutThreshold {
+			sim.paid[c.Ticker] += sim.unpaid[c.Ticker]
+			sim.unpaid[c.Ticker] = 0
+		}
+	}
+}
+
+// profileHashrate returns the total hashrate of the workers on profileID.
+func (sim *Simulator) profileHashrate(profileID string) float64 {
+	var total float64
+	for _, wk := range sim.workers {
+		if wk.ProfileID == profileID {
+			total += float64(wk.Hashrate)
+		}
+	}
+	return total
+}
+
+func (sim *Simulator) handleMinerStats(w http.ResponseWriter, r *http.Request) {
+	coin := strings.ToUpper(r.PathValue("coin"))
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	for _, c := range sim.script.Coins {
+		if c.Ticker == coin {
+			writeJSON(w, map[string]float64{
+				"unpaid":   sim.unpaid[c.Ticker],
+				"paid":     sim.paid[c.Ticker],
+				"hashrate": sim.profileHashrate(c.ProfileID),
+			})
+			return
+		}
+	}
+	http.Error(w, "unknown coin", http.StatusNotFound)
+}
+
+// value returns the coin's price or revenue at the current tick.
+func (sim *Simulator) value(c SimCoin, kind string, path []float64, walk SimWalk) float64 {
+	return sim.valueAt(c, kind, path, walk, sim.tick)
+}
+
+// valueAt returns the coin's price or revenue at an earlier or current tick.
+func (sim *Simulator) valueAt(c SimCoin, kind string, path []float64, walk SimWalk, tick int) float64 {
+	if len(path) > 0 {
+		i := tick - 1
+		if i >= len(path) {
+			i = len(path) - 1
+		}
+		if i < 0 {
+			i = 0
+		}
+		return path[i]
+	}
+	key := kind + ":" + c.Ticker
+	s := sim.series[key]
+	if len(s) == 0 {
+		s = append(s, walk.Start)
+	}
+	for len(s) < sim.tick {
+		next := s[len(s)-1] * (1 + sim.rng.NormFloat64()*walk.Volatility)
+		s = append(s, math.Max(next, 0))
+	}
+	sim.series[key] = s
+	return s[max(tick, 1)-1]
+}
+
+func (sim *Simulator) handleWorkers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 100
+	}
+	algo := q.Get("algorithm")
+	sim.mu.Lock()
+	var matched []Worker
+	for _, wk := range sim.workers {
+		if algo == "" || strings.EqualFold(algo, wk.Algorithm) {
+			matched = append(matched, wk)
+		}
+	}
+	sim.mu.Unlock()
+	start := min((page-1)*limit, len(matched))
+	end := min(start+limit, len(matched))
+	writeJSON(w, WorkersResponse{
+		Data: matched[start:end],
+		Pagination: Pagination{
+			Page: page, Limit: limit, Total: len(matched),
+			TotalPages: (len(matched) + limit - 1) / limit,
+		},
+	})
+}
+
+func (sim *Simulator) handleHashrate(w http.ResponseWriter, r *http.Request) {
+	hours, ok := hashrateRanges[r.URL.Query().Get("timeRange")]
+	if !ok {
+		http.Error(w, "invalid timeRange", http.StatusBadRequest)
+		return
+	}
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	var total float64
+	for _, wk := range sim.workers {
+		total += float64(wk.Hashrate)
+	}
+	// ±2% noise so the daemon sees a live-looking value
+	avg := total * (1 + (sim.rng.Float64()-0.5)*0.04)
+	writeJSON(w, HashrateResponse{Hours: hours, Stats: &HashrateStats{AvgHashrate: avg, PeakHashrate: total * 1.05}})
+}
+
+func (sim *Simulator) handleBulkAssign(w http.ResponseWriter, r *http.Request) {
+	var req BulkAssignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if !sim.hasProfile(req.ProfileID) {
+		http.Error(w, "unknown profile", http.StatusNotFound)
+		return
+	}
+	ids := make(map[string]bool, len(req.WorkerIDs))
+	for _, id := range req.WorkerIDs {
+		ids[id] = true
+	}
+	n := 0
+	for i := range sim.workers {
+		if ids[sim.workers[i].ID] {
+			sim.workers[i].ProfileID = req.ProfileID
+			n++
+		}
+	}
+	sim.switches++
+	slog.Info("Workers assigned", "tick", sim.tick, "profile_id", req.ProfileID, "worker_count", n)
+	writeJSON(w, map[string]int{"updated": n})
+}
+
+func (sim *Simulator) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	out := make([]Profile, len(sim.profiles))
+	for i, p := range sim.profiles {
+		p.IsDefault = p.ID == sim.defaultP
+		out[i] = p
+	}
+	writeJSON(w, ProfilesResponse{Data: out, Pagination: Pagination{Page: 1, Limit: 100, Total: len(out), TotalPages: 1}})
+}
+
+func (sim *Simulator) handleDefault(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if !sim.hasProfile(id) {
+		http.Error(w, "unknown profile", http.StatusNotFound)
+		return
+	}
+	sim.defaultP = id
+	writeJSON(w, map[string]bool{"ok": true})
+}
+
+func (sim *Simulator) hasProfile(id string) bool {
+	for _, p := range sim.profiles {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// simConfig renders a daemon config pointing at a simulator on baseURL.
+func (sim *Simulator) simConfig(baseURL string, interval int) []byte {
+	coins := make([]CoinConfig, 0, len(sim.script.Coins))
+	for _, c := range sim.script.Coins {
+		coins = append(coins, CoinConfig{Ticker: c.Ticker, RevenueTicker: c.RevenueTicker, ProfileID: c.ProfileID, Wallet: c.Wallet})
+	}
+	key := sim.script.APIKey
+	if key == "" {
+		// Every non-empty key is redacted from logs, so the default must not
+		// occur in ordinary output.
+		key = "sim-key-3f9c2a7b"
+	}
+	data := renderInitConfig(key, "", baseURL+"/proxy", baseURL+"/kryptex", sim.script.Algorithm, "USD", interval,
+		int(sim.script.Hashrate)*sim.script.Workers, coins)
+	data = append(data, []byte("\nhistory_file: \"sim_history.json\"\n")...)
+	data = fmt.Appendf(data, "kryptex_stats_url: %q\n", baseURL+"/kryptex/miner-stats/{coin}/{wallet}")
+	// Every /rates call is a tick: a cached response would freeze the simulation
+	data = append(data, []byte("http_cache: {}\n")...)
+
+	// Price sources served by the simulator, to cross-check the Kryptex rates
+	var b strings.Builder
+	b.WriteString("\nprice_sources:\n")
+	fmt.Fprintf(&b, "  - name: exchange\n    type: ticker\n    url: %q\n    field: price\n", baseURL+"/exchange/ticker/{COIN}")
+	fmt.Fprintf(&b, "  - name: coingecko\n    type: coingecko\n    url: %q\n    symbols:\n      BTC: btc\n", baseURL+"/coingecko/simple/price")
+	for _, c := range sim.script.Coins {
+		fmt.Fprintf(&b, "      %s: %s\n", c.Ticker, strings.ToLower(c.Ticker))
+	}
+	return append(data, b.String()...)
+}
+
+// cmdSimulate implements the `simulate` subcommand: serve the mock APIs and
+// optionally write a config that points the daemon at them.
+func cmdSimulate(args []string) int {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	scriptPath := fs.String("script", "", "Simulation script (YAML); a built-in random walk is used if empty")
+	listen := fs.String("listen", "127.0.0.1:8700", "Address to serve the mock APIs on")
+	writeConfig := fs.String("write-config", "", "Write a daemon config pointing at the simulator to this path")
+	interval := fs.Int("interval", 10, "Interval (seconds) for the written config and the time each tick credits to pool balances")
+	fs.Parse(args)
+
+	script := defaultSimScript()
+	if *scriptPath != "" {
+		data, err := os.ReadFile(*scriptPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read script: %v\n", err)
+			return 1
+		}
+		script = SimScript{}
+		if err := yaml.Unmarshal(data, &script); err != nil {
+			fmt.Fprintf(os.Stderr, "parse script: %v\n", err)
+			return 1
+		}
+	}
+	if len(script.Coins) == 0 {
+		fmt.Fprintln(os.Stderr, "script has no coins")
+		return 1
+	}
+	if script.TickSecs <= 0 {
+		script.TickSecs = *interval
+	}
+	sim := NewSimulator(script)
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	base := "http://" + ln.Addr().String()
+	if *writeConfig != "" {
+		if err := os.WriteFile(*writeConfig, sim.simConfig(base, *interval), 0600); err != nil {
+			fmt.Fprintf(os.Stderr, "write config: %v\n", err)
+			return 1
+		}
+		slog.Info("Wrote simulator config", "path", *writeConfig, "run", "ultimate-proxy-profile-switcher -c "+*writeConfig)
+	}
+	tickers := make([]string, 0, len(script.Coins))
+	for _, c := range sim.script.Coins {
+		tickers = append(tickers, c.Ticker)
+	}
+	sort.Strings(tickers)
+	slog.Info("Kryptex mock", "url", base+"/kryptex")
+	slog.Info("Ultimate Proxy mock", "url", base+"/proxy")
+	slog.Info("Simulator ready", "workers", len(sim.workers), "coins", strings.Join(tickers, ","), "faults", len(script.Faults))
+	if err := http.Serve(ln, sim.Handler()); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// TestSimulatorLoop runs daemon cycles against the simulator: the first cycle
+// starts on the most profitable coin, and when the prices cross over the
+// workers move to the other profile and the switch is recorded.
+func TestSimulatorLoop(t *testing.T) {
+	sim := NewSimulator(SimScript{
+		Workers: 3,
+		Coins: []SimCoin{
+			{Ticker: "AAA", Prices: []float64{100}, RevenuePerMH: []float64{1}},
+			{Ticker: "BBB", Prices: []float64{80, 80, 130}, RevenuePerMH: []float64{1}},
+		},
+	})
+	srv := httptest.NewServer(sim.Handler())
+	defer srv.Close()
+	// cmdRun replaces the HTTP client, output style and logger
+	defer func(c *http.Client, st outputStyle, l *slog.Logger) {
+		httpClient, outStyle = c, st
+		slog.SetDefault(l)
+	}(httpClient, outStyle, slog.Default())
+
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("config.yaml", sim.simConfig(srv.URL, 60), 0600); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-c", "config.yaml", "-once", "-output", "none", "-log-level", "error", "-color", "never"}
+	for i := 0; i < 3; i++ {
+		if code := cmdRun(args); code != 0 {
+			t.Fatalf("cycle %d: exit code %d", i+1, code)
+		}
+	}
+
+	for _, w := range sim.workers {
+		if w.ProfileID != "sim-bbb" {
+			t.Errorf("worker %s on %s, want sim-bbb", w.ID, w.ProfileID)
+		}
+	}
+	if sim.defaultP != "sim-bbb" {
+		t.Errorf("default profile = %q, want sim-bbb", sim.defaultP)
+	}
+
+	snaps, err := readHistoryFile("sim_history.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 3 {
+		t.Fatalf("recorded %d snapshots, want 3", len(snaps))
+	}
+	for i, want := range []struct {
+		mining   string
+		switched bool
+	}{{"AAA", false}, {"AAA", false}, {"BBB", true}} {
+		s := snaps[i]
+		if s.Mining != want.mining || s.Switched != want.switched {
+			t.Errorf("cycle %d: mining %s switched %v, want %s %v", i+1, s.Mining, s.Switched, want.mining, want.switched)
+		}
+	}
+	if last := snaps[2]; last.WorkersSwitched != 3 || last.Reason == "" {
+		t.Errorf("switch recorded %d workers with reason %q, want 3 and a reason", last.WorkersSwitched, last.Reason)
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store is an embedded, file-based time-series store for snapshots.
+//
+// Raw snapshots are appended to one NDJSON file per UTC day under raw/. Days
+// older than the raw retention are downsampled into hourly aggregates
+// (hourly/YYYY-MM.jsonl), and months of hourly data older than the hourly
+// retention into daily aggregates (daily/YYYY.jsonl). A tier is deleted only
+// after it has been written to the next one, so ranges never overlap.
+type Store struct {
+	mu          sync.Mutex
+	dir         string
+	rawKeep     time.Duration
+	hourlyKeep  time.Duration
+	dailyKeep   time.Duration // 0 = forever
+	lastCompact time.Time
+}
+
+const (
+	tierRaw    = "raw"
+	tierHourly = "hourly"
+	tierDaily  = "daily"
+)
+
+// OpenStore opens (creating if needed) a store in dir. Retentions are in days;
+// dailyDays <= 0 keeps daily aggregates forever.
+func OpenStore(dir string, rawDays, hourlyDays, dailyDays int) (*Store, error) {
+	for _, tier := range []string{tierRaw, tierHourly, tierDaily} {
+		if err := os.MkdirAll(filepath.Join(dir, tier), 0755); err != nil {
+			return nil, fmt.Errorf("open store: %w", err)
+		}
+	}
+	day := 24 * time.Hour
+	return &Store{
+		dir:        dir,
+		rawKeep:    time.Duration(rawDays) * day,
+		hourlyKeep: time.Duration(hourlyDays) * day,
+		dailyKeep:  time.Duration(dailyDays) * day,
+	}, nil
+}
+
+// Append adds a raw snapshot and downsamples expired data at most once an hour.
+func (st *Store) Append(s Snapshot) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	path := filepath.Join(st.dir, tierRaw, s.Time.UTC().Format("2006-01-02")+".jsonl")
+	if err := appendJSONLines(path, []Snapshot{s}); err != nil {
+		return fmt.Errorf("store append: %w", err)
+	}
+	if time.Since(st.lastCompact) >= time.Hour {
+		st.lastCompact = time.Now()
+		if err := st.compact(time.Now()); err != nil {
+			return fmt.Errorf("store compact: %w", err)
+		}
+	}
+	return nil
+}
+
+// Query returns every snapshot or aggregate with from <= Time < to, oldest
+// first. Aggregates carry their sample count in Snapshot.Samples.
+func (st *Store) Query(from, to time.Time) ([]Snapshot, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var out []Snapshot
+	for _, tier := range []string{tierDaily, tierHourly, tierRaw} {
+		files, err := st.files(tier)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			start, end := f.span()
+			if !end.After(from) || !start.Before(to) {
+				continue
+			}
+			snaps, err := readJSONLines(f.path)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range snaps {
+				if !s.Time.Before(from) && s.Time.Before(to) {
+					out = append(out, s)
+				}
+			}
+		}
+	}
+	return dedupeByTime(out), nil
+}
+
+// Averages computes per-coin averages over [from, to), weighting aggregates by
+// the number of raw samples they represent.
+func (st *Store) Averages(from, to time.Time) ([]CoinAverage, MinedAverage, error) {
+	snaps, err := st.Query(from, to)
+	if err != nil {
+		return nil, MinedAverage{}, err
+	}
+	avgs, mined := averagesOf(snaps)
+	return avgs, mined, nil
+}
+
+func (st *Store) compact(now time.Time) error {
+	// raw days -> hourly
+	raw, err := st.files(tierRaw)
+	if err != nil {
+		return err
+	}
+	for _, f := range raw {
+		if _, end := f.span(); end.After(now.Add(-st.rawKeep)) {
+			continue
+		}
+		if err := st.downsample(f, tierHourly, time.Hour, "2006-01"); err != nil {
+			return err
+		}
+	}
+	// hourly months -> daily
+	hourly, err := st.files(tierHourly)
+	if err != nil {
+		return err
+	}
+	for _, f := range hourly {
+		if _, end := f.span(); end.After(now.Add(-st.hourlyKeep)) {
+			continue
+		}
+		if err := st.downsample(f, tierDaily, 24*time.Hour, "2006"); err != nil {
+			return err
+		}
+	}
+	// daily retention
+	if st.dailyKeep <= 0 {
+		return nil
+	}
+	daily, err := st.files(tierDaily)
+	if err != nil {
+		return err
+	}
+	for _, f := range daily {
+		if _, end := f.span(); !end.After(now.Add(-st.dailyKeep)) {
+			if err := os.Remove(f.path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// downsample aggregates file f into bucket-sized points appended to the
+// target tier (files named by nameLayout), then removes f. It is idempotent:
+// after a crash between the append and the removal, the next run skips the
+// buckets already written, and duplicates in f itself are counted once.
+func (st *Store) downsample(f storeFile, tier string, bucket time.Duration, nameLayout string) error {
+	snaps, err := readJSONLines(f.path)
+	if err != nil {
+		return err
+	}
+	byFile := make(map[string][]Snapshot)
+	for _, a := range aggregate(dedupeByTime(snaps), bucket) {
+		name := a.Time.UTC().Format(nameLayout) + ".jsonl"
+		byFile[name] = append(byFile[name], a)
+	}
+	for name, aggs := range byFile {
+		path := filepath.Join(st.dir, tier, name)
+		existing, err := readJSONLines(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		written := make(map[time.Time]bool, len(existing))
+		for _, s := range existing {
+			written[s.Time.UTC()] = true
+		}
+		var missing []Snapshot
+		for _, a := range aggs {
+			if !written[a.Time.UTC()] {
+				missing = append(missing, a)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		if err := appendJSONLines(path, missing); err != nil {
+			return err
+		}
+	}
+	return os.Remove(f.path)
+}
+
+type storeFile struct {
+	path   string
+	tier   string
+	period time.Time
+}
+
+// span returns the time range covered by the file's name.
+func (f storeFile) span() (time.Time, time.Time) {
+	switch f.tier {
+	case tierRaw:
+		return f.period, f.period.AddDate(0, 0, 1)
+	case tierHourly:
+		return f.period, f.period.AddDate(0, 1, 0)
+	default:
+		return f.period, f.period.AddDate(1, 0, 0)
+	}
+}
+
+func (st *Store) files(tier string) ([]storeFile, error) {
+	layout := map[string]string{tierRaw: "2006-01-02", tierHourly: "2006-01", tierDaily: "2006"}[tier]
+	entries, err := os.ReadDir(filepath.Join(st.dir, tier))
+	if err != nil {
+		return nil, err
+	}
+	var out []storeFile
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".jsonl")
+		if !ok || e.IsDir() {
+			continue
+		}
+		t, err := time.ParseInLocation(layout, name, time.UTC)
+		if err != nil {
+			continue
+		}
+		out = append(out, storeFile{path: filepath.Join(st.dir, tier, e.Name()), tier: tier, period: t})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].period.Before(out[j].period) })
+	return out, nil
+}
+
+// aggregate groups snapshots into buckets of the given width. Each bucket
+// holds the sample-weighted mean per coin, the most-mined coin, whether any
+// switch happened, and the total sample count. Earned is cumulative, so the
+// bucket keeps the last value seen for each coin.
+func aggregate(snaps []Snapshot, bucket time.Duration) []Snapshot {
+	type acc struct {
+		sumFiat, sumBTC map[string]float64
+		wFiat, wBTC     map[string]float64
+		prices, coinRev weightedMeans
+		poolHR          weightedMeans
+		earned          map[string]float64
+		hashrate, fiat  weightedMean
+		mined           map[string]int
+		switched        bool
+		workers         int
+		samples         int
+	}
+	buckets := make(map[time.Time]*acc)
+	var keys []time.Time
+	for _, s := range snaps {
+		k := s.Time.UTC().Truncate(bucket)
+		a, ok := buckets[k]
+		if !ok {
+			a = &acc{
+				sumFiat: map[string]float64{}, sumBTC: map[string]float64{},
+				wFiat: map[string]float64{}, wBTC: map[string]float64{},
+				prices: weightedMeans{}, coinRev: weightedMeans{},
+				poolHR: weightedMeans{}, earned: map[string]float64{},
+				mined: map[string]int{},
+			}
+			buckets[k] = a
+			keys = append(keys, k)
+		}
+		w := s.weight()
+		for t, v := range s.Coins {
+			a.sumFiat[t] += v * float64(w)
+			a.wFiat[t] += float64(w)
+		}
+		for t, v := range s.CoinsBTC {
+			a.sumBTC[t] += v * float64(w)
+			a.wBTC[t] += float64(w)
+		}
+		for t, v := range s.Prices {
+			a.prices.add(t, v, w)
+		}
+		for t, v := range s.CoinRevenue {
+			a.coinRev.add(t, v, w)
+		}
+		for t, v := range s.PoolHashrate {
+			a.poolHR.add(t, v, w)
+		}
+		for t, v := range s.Earned {
+			a.earned[t] = v
+		}
+		if s.Hashrate > 0 {
+			a.hashrate.add(s.Hashrate, w)
+		}
+		if s.FiatRate > 0 {
+			a.fiat.add(s.FiatRate, w)
+		}
+		if s.Mining != "" {
+			a.mined[s.Mining] += w
+		}
+		a.switched = a.switched || s.Switched
+		a.workers += s.WorkersSwitched
+		a.samples += w
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Before(keys[j]) })
+
+	out := make([]Snapshot, 0, len(keys))
+	for _, k := range keys {
+		a := buckets[k]
+		s := Snapshot{
+			Time:     k,
+			Coins:    make(map[string]float64, len(a.sumFiat)),
+			CoinsBTC: make(map[string]float64, len(a.sumBTC)),
+			Switched: a.switched,
+			Samples:  a.samples,
+
+			Hashrate:        a.hashrate.mean(),
+			Prices:          a.prices.means(),
+			CoinRevenue:     a.coinRev.means(),
+			FiatRate:        a.fiat.mean(),
+			WorkersSwitched: a.workers,
+			PoolHashrate:    a.poolHR.means(),
+		}
+		if len(a.earned) > 0 {
+			s.Earned = a.earned
+		}
+		for t, v := range a.sumFiat {
+			s.Coins[t] = v / a.wFiat[t]
+		}
+		for t, v := range a.sumBTC {
+			s.CoinsBTC[t] = v / a.wBTC[t]
+		}
+		best := 0
+		for t, n := range a.mined {
+			if n > best || (n == best && t < s.Mining) {
+				s.Mining, best = t, n
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+type weightedMean struct {
+	sum, weight float64
+}
+
+func (m *weightedMean) add(v float64, w int) {
+	m.sum += v * float64(w)
+	m.weight += float64(w)
+}
+
+func (m weightedMean) mean() float64 {
+	if m.weight == 0 {
+		return 0
+	}
+	return m.sum / m.weight
+}
+
+// weightedMeans keeps a weightedMean per ticker.
+type weightedMeans map[string]*weightedMean
+
+func (m weightedMeans) add(ticker string, v float64, w int) {
+	if m[ticker] == nil {
+		m[ticker] = &weightedMean{}
+	}
+	m[ticker].add(v, w)
+}
+
+// means returns the mean per ticker, or nil if nothing was added.
+func (m weightedMeans) means() map[string]float64 {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]float64, len(m))
+	for t, wm := range m {
+		out[t] = wm.mean()
+	}
+	return out
+}
+
+// dedupeByTime sorts snapshots by time and keeps the last of any duplicates,
+// which can appear if a downsample was interrupted before removing its source.
+func dedupeByTime(snaps []Snapshot) []Snapshot {
+	sort.SliceStable(snaps, func(i, j int) bool { return snaps[i].Time.Before(snaps[j].Time) })
+	out := snaps[:0]
+	for _, s := range snaps {
+		if n := len(out); n > 0 && out[n-1].Time.Equal(s.Time) {
+			out[n-1] = s
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// appendJSONLines appends one snapshot per line. If a crash left the file
+// ending in a partial line, a newline is written first so that the partial
+// line stays on its own (and is skipped by readJSONLines) instead of
+// corrupting the first appended snapshot.
+func appendJSONLines(path string, snaps []Snapshot) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if info, err := f.Stat(); err != nil {
+		f.Close()
+		return err
+	} else if size := info.Size(); size > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, size-1); err != nil {
+			f.Close()
+			return err
+		}
+		if last[0] != '\n' {
+			w.WriteByte('\n')
+		}
+	}
+	enc := json.NewEncoder(w)
+	for _, s := range snaps {
+		if err := enc.Encode(s); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readJSONLines reads one snapshot per line, skipping lines that fail to parse
+// (e.g. a partial line left by a crash mid-append).
+func readJSONLines(path string) ([]Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []Snapshot
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var s Snapshot
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out, sc.Err()
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTestStore opens a store in a temporary directory that does not compact
+// on Append, so tests control when compaction runs.
+func openTestStore(t *testing.T, rawDays, hourlyDays, dailyDays int) *Store {
+	t.Helper()
+	st, err := OpenStore(t.TempDir(), rawDays, hourlyDays, dailyDays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.lastCompact = time.Now()
+	return st
+}
+
+func appendAll(t *testing.T, st *Store, snaps ...Snapshot) {
+	t.Helper()
+	for _, s := range snaps {
+		if err := st.Append(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	snaps := []Snapshot{
+		{Time: t0.Add(5 * time.Minute), Coins: map[string]float64{"A": 1, "B": 4}, Mining: "A", Hashrate: 100, Earned: map[string]float64{"A": 1}},
+		{Time: t0.Add(20 * time.Minute), Coins: map[string]float64{"A": 3}, Mining: "B", Switched: true, WorkersSwitched: 2, Hashrate: 200, Earned: map[string]float64{"A": 2}},
+		// an earlier aggregate of 2 samples counts twice
+		{Time: t0.Add(40 * time.Minute), Coins: map[string]float64{"A": 5, "B": 1}, Mining: "A", Samples: 2, Hashrate: 100},
+		{Time: t0.Add(70 * time.Minute), Coins: map[string]float64{"A": 7}, Mining: "B"},
+	}
+	got := aggregate(snaps, time.Hour)
+	if len(got) != 2 {
+		t.Fatalf("got %d buckets, want 2", len(got))
+	}
+	first := got[0]
+	if !first.Time.Equal(t0) || first.Samples != 4 {
+		t.Errorf("first bucket at %v with %d samples, want %v and 4", first.Time, first.Samples, t0)
+	}
+	// A: (1 + 3 + 5*2) / 4; B: (4 + 1*2) / 3
+	if !approxEqual(first.Coins["A"], 3.5) || !approxEqual(first.Coins["B"], 2) {
+		t.Errorf("first bucket coins = %v, want A 3.5, B 2", first.Coins)
+	}
+	if first.Mining != "A" || !first.Switched || first.WorkersSwitched != 2 {
+		t.Errorf("first bucket mining %s switched %v workers %d, want A true 2", first.Mining, first.Switched, first.WorkersSwitched)
+	}
+	if !approxEqual(first.Hashrate, 125) || first.Earned["A"] != 2 {
+		t.Errorf("first bucket hashrate %g earned %v, want 125 and the last earned value 2", first.Hashrate, first.Earned)
+	}
+	if second := got[1]; !second.Time.Equal(t0.Add(time.Hour)) || second.Samples != 1 || second.Switched || second.Coins["A"] != 7 {
+		t.Errorf("second bucket = %+v", second)
+	}
+}
+
+func TestStoreCompactTiers(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	st := openTestStore(t, 2, 40, 400)
+	recent := now.Add(-time.Hour)
+	lastWeek := time.Date(2026, 6, 10, 8, 15, 0, 0, time.UTC)
+	march := time.Date(2026, 3, 5, 10, 10, 0, 0, time.UTC)
+	expired := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	appendAll(t, st,
+		Snapshot{Time: expired, Coins: map[string]float64{"A": 9}},
+		Snapshot{Time: march, Coins: map[string]float64{"A": 1}},
+		Snapshot{Time: march.Add(30 * time.Minute), Coins: map[string]float64{"A": 3}},
+		Snapshot{Time: march.Add(24 * time.Hour), Coins: map[string]float64{"A": 5}},
+		Snapshot{Time: lastWeek, Coins: map[string]float64{"A": 2}},
+		Snapshot{Time: lastWeek.Add(10 * time.Minute), Coins: map[string]float64{"A": 4}},
+		Snapshot{Time: recent, Coins: map[string]float64{"A": 6}},
+	)
+	if err := st.compact(now); err != nil {
+		t.Fatal(err)
+	}
+
+	for tier, want := range map[string][]string{
+		tierRaw:    {"2026-06-15.jsonl"},
+		tierHourly: {"2026-06.jsonl"},
+		tierDaily:  {"2026.jsonl"},
+	} {
+		entries, err := os.ReadDir(filepath.Join(st.dir, tier))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if len(names) != len(want) || names[0] != want[0] {
+			t.Errorf("%s files = %v, want %v", tier, names, want)
+		}
+	}
+
+	got, err := st.Query(time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		time    time.Time
+		samples int
+		a       float64
+	}{
+		{time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC), 2, 2}, // daily from two hourly points of one sample each
+		{time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC), 1, 5},
+		{time.Date(2026, 6, 10, 8, 0, 0, 0, time.UTC), 2, 3}, // hourly
+		{recent, 0, 6}, // raw
+	}
+	if len(got) != len(want) {
+		t.Fatalf("query returned %d snapshots, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if !got[i].Time.Equal(w.time) || got[i].Samples != w.samples || !approxEqual(got[i].Coins["A"], w.a) {
+			t.Errorf("snapshot %d = %v samples %d A %g, want %v samples %d A %g", i, got[i].Time, got[i].Samples, got[i].Coins["A"], w.time, w.samples, w.a)
+		}
+	}
+
+	// Compacting again changes nothing
+	if err := st.compact(now); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := st.Query(time.Time{}, now); err != nil || len(again) != len(want) {
+		t.Errorf("second compaction left %d snapshots (err %v), want %d", len(again), err, len(want))
+	}
+}
+
+func TestStoreQueryBounds(t *testing.T) {
+	st := openTestStore(t, 7, 90, 0)
+	t0 := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		// 23:00, 23:30, 00:00 and 00:30: the range spans two raw files
+		appendAll(t, st, Snapshot{Time: t0.Add(time.Duration(i) * 30 * time.Minute), Coins: map[string]float64{"A": float64(i)}})
+	}
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []float64
+	}{
+		{"from is inclusive, to exclusive", t0.Add(30 * time.Minute), t0.Add(90 * time.Minute), []float64{1, 2}},
+		{"across files", t0, t0.Add(2 * time.Hour), []float64{0, 1, 2, 3}},
+		{"empty range", t0.Add(10 * time.Minute), t0.Add(20 * time.Minute), nil},
+		{"before any data", t0.AddDate(0, -1, 0), t0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := st.Query(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d snapshots, want %d", len(got), len(tt.want))
+			}
+			for i, s := range got {
+				if s.Coins["A"] != tt.want[i] {
+					t.Errorf("snapshot %d: A = %g, want %g", i, s.Coins["A"], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStoreAppendAfterCrash(t *testing.T) {
+	st := openTestStore(t, 7, 90, 0)
+	t0 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	appendAll(t, st, Snapshot{Time: t0, Coins: map[string]float64{"A": 1}})
+
+	// A crash mid-append leaves a partial last line
+	path := filepath.Join(st.dir, tierRaw, "2026-03-01.jsonl")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"Time":"2026-03-01T10:05:00Z","Coins":{"A":`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	appendAll(t, st, Snapshot{Time: t0.Add(10 * time.Minute), Coins: map[string]float64{"A": 2}})
+	got, err := st.Query(t0, t0.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Coins["A"] != 1 || got[1].Coins["A"] != 2 {
+		t.Errorf("after crash got %+v, want the snapshots before and after it", got)
+	}
+}
+
+func TestStoreDownsampleAfterCrash(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	st := openTestStore(t, 2, 40, 0)
+	t0 := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		appendAll(t, st, Snapshot{Time: t0.Add(time.Duration(i) * 15 * time.Minute), Coins: map[string]float64{"A": float64(i)}})
+	}
+	rawPath := filepath.Join(st.dir, tierRaw, "2026-06-01.jsonl")
+	raw, err := os.ReadFile(rawPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.compact(now); err != nil {
+		t.Fatal(err)
+	}
+	// A crash after the hourly append but before the raw file was removed
+	if err := os.WriteFile(rawPath, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.compact(now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(rawPath); !os.IsNotExist(err) {
+		t.Errorf("raw file still present after compaction: %v", err)
+	}
+	hourly, err := readJSONLines(filepath.Join(st.dir, tierHourly, "2026-06.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hourly) != 1 || hourly[0].Samples != 4 {
+		t.Fatalf("hourly tier = %+v, want one aggregate of 4 samples", hourly)
+	}
+
+	// Duplicates that an older version left in the hourly tier are counted once
+	if err := appendJSONLines(filepath.Join(st.dir, tierHourly, "2026-06.jsonl"), hourly); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.compact(now.AddDate(0, 2, 0)); err != nil {
+		t.Fatal(err)
+	}
+	daily, err := readJSONLines(filepath.Join(st.dir, tierDaily, "2026.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daily) != 1 || daily[0].Samples != 4 || !approxEqual(daily[0].Coins["A"], 1.5) {
+		t.Errorf("daily tier = %+v, want one aggregate of 4 samples with A 1.5", daily)
+	}
+}
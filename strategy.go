@@ -0,0 +1,210 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// Policy controls when the switcher leaves the coin it is currently mining.
+// The zero Policy always switches to the most profitable coin.
+type Policy struct {
+	Threshold float64       // minimum gain over the current coin, in percent
+	MinDwell  time.Duration // minimum time on a coin before switching away
+	Smoothing int           // rank coins on the mean of the last N samples (<= 1 = latest only)
+
+	// Payout preferences, applied to coins listed in Decider.DaysToPayout.
+	PayoutMaxDays    float64 // avoid coins needing longer than this to reach payout (0 = off)
+	PayoutFinishDays float64 // prefer a coin reaching payout within this many days (0 = off)...
+	PayoutMargin     float64 // ...if its score is at most this many percent below the best
+}
+
+func policyFromConfig(cfg *Config) Policy {
+	return Policy{
+		Threshold: cfg.SwitchThreshold,
+		MinDwell:  time.Duration(cfg.MinDwell) * time.Second,
+		Smoothing: cfg.Smoothing,
+
+		PayoutMaxDays:    cfg.PayoutMaxDays,
+		PayoutFinishDays: cfg.PayoutFinishDays,
+		PayoutMargin:     cfg.PayoutMargin,
+	}
+}
+
+// Decision is the outcome of one Decider step.
+type Decision struct {
+	Ticker  string  // coin to mine
+	Best    string  // most profitable coin after smoothing and payout preferences
+	Switch  bool    // Ticker differs from the current coin
+	GainPct float64 // smoothed gain of Best over the current coin (0 if unknown)
+	Reason  string  // one of the reason* constants
+}
+
+// Decider applies a Policy to successive profitability samples. It has no I/O
+// so the daemon and the backtester share exactly the same logic.
+type Decider struct {
+	Policy  Policy
+	Current string    // coin currently mined ("" before the first decision)
+	Since   time.Time // when Current was selected
+	Pinned  string    // coin to mine regardless of profitability ("" = none)
+	Paused  bool      // keep mining Current, never switch away
+
+	// DaysToPayout is the estimated time to reach the payout threshold per
+	// coin, set before each Decide. Coins without a threshold are absent.
+	DaysToPayout map[string]float64
+
+	windows map[string][]float64
+}
+
+func NewDecider(p Policy) *Decider {
+	return &Decider{Policy: p, windows: make(map[string][]float64)}
+}
+
+// Observe feeds a sample into the smoothing windows without deciding.
+func (d *Decider) Observe(values map[string]float64) {
+	n := d.Policy.Smoothing
+	if n < 1 {
+		n = 1
+	}
+	for t, v := range values {
+		w := append(d.windows[t], v)
+		if len(w) > n {
+			w = w[len(w)-n:]
+		}
+		d.windows[t] = w
+	}
+}
+
+// Prime restores the decider from history: smoothing windows, the mined coin
+// and when it was selected.
+func (d *Decider) Prime(snaps []Snapshot) {
+	for _, s := range snaps {
+		d.Observe(s.Coins)
+		if s.Mining != "" && s.Mining != d.Current {
+			d.Current, d.Since = s.Mining, s.Time
+		}
+	}
+}
+
+// Decide observes values (ticker -> daily fiat revenue) and returns which coin
+// to mine. It does not change Current; call Commit once the switch succeeded.
+func (d *Decider) Decide(now time.Time, values map[string]float64) Decision {
+	d.Observe(values)
+
+	scores := make(map[string]float64, len(values))
+	for t := range values {
+		scores[t] = mean(d.windows[t])
+	}
+	tickers := make([]string, 0, len(scores))
+	for t := range scores {
+		tickers = append(tickers, t)
+	}
+	sort.Strings(tickers)
+	best := ""
+	for _, t := range tickers {
+		if best == "" || scores[t] > scores[best] {
+			best = t
+		}
+	}
+
+	reason := reasonBest
+	if p := d.payoutBest(scores, tickers, best); p != best {
+		best, reason = p, reasonPayout
+	}
+
+	dec := Decision{Ticker: d.Current, Best: best, Reason: reason}
+	if _, ok := values[d.Pinned]; ok && d.Pinned != "" {
+		dec.Ticker, dec.Switch, dec.Reason = d.Pinned, d.Pinned != d.Current, reasonPinned
+		return dec
+	}
+	if d.Paused && d.Current != "" {
+		if best != d.Current {
+			dec.Reason = reasonPaused
+		}
+		return dec
+	}
+	if best == "" || best == d.Current {
+		return dec
+	}
+	cur, ok := scores[d.Current]
+	if d.Current != "" && !ok {
+		// A coin that failed to fetch is not known to be worse: never leave it on absence alone
+		dec.Reason = reasonMissing
+		return dec
+	}
+	if d.Current != "" {
+		if cur > 0 {
+			dec.GainPct = (scores[best] - cur) / cur * 100
+		}
+		if reason != reasonPayout && d.Policy.Threshold > 0 && cur > 0 && dec.GainPct < d.Policy.Threshold {
+			dec.Reason = reasonThreshold
+			return dec
+		}
+		if d.Policy.MinDwell > 0 && now.Sub(d.Since) < d.Policy.MinDwell {
+			dec.Reason = reasonDwell
+			return dec
+		}
+	}
+	dec.Ticker = best
+	dec.Switch = true
+	return dec
+}
+
+// payoutBest applies the payout preferences to the most profitable coin best:
+// a coin needing more than PayoutMaxDays is avoided when another coin is
+// within reach, and a coin reaching payout within PayoutFinishDays is chosen
+// when it earns at most PayoutMargin percent less than best.
+func (d *Decider) payoutBest(scores map[string]float64, tickers []string, best string) string {
+	p, days := d.Policy, d.DaysToPayout
+	if best == "" || len(days) == 0 {
+		return best
+	}
+	if dd, ok := days[best]; ok && p.PayoutMaxDays > 0 && dd > p.PayoutMaxDays {
+		alt := ""
+		for _, t := range tickers {
+			if dd, ok := days[t]; ok && dd > p.PayoutMaxDays {
+				continue
+			}
+			if alt == "" || scores[t] > scores[alt] {
+				alt = t
+			}
+		}
+		if alt != "" {
+			best = alt
+		}
+	}
+	if p.PayoutFinishDays > 0 {
+		floor := scores[best] * (1 - p.PayoutMargin/100)
+		finish := ""
+		for _, t := range tickers {
+			dd, ok := days[t]
+			if !ok || dd <= 0 || dd > p.PayoutFinishDays || scores[t] < floor {
+				continue
+			}
+			if finish == "" || dd < days[finish] {
+				finish = t
+			}
+		}
+		if finish != "" {
+			best = finish
+		}
+	}
+	return best
+}
+
+// Commit records that ticker is now being mined.
+func (d *Decider) Commit(ticker string, now time.Time) {
+	if ticker != d.Current {
+		d.Current, d.Since = ticker, now
+	}
+}
+
+func mean(vs []float64) float64 {
+	if len(vs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range vs {
+		sum += v
+	}
+	return sum / float64(len(vs))
+}
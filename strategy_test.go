@@ -0,0 +1,227 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecider(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	type step struct {
+		values     map[string]float64
+		wantTicker string
+		wantReason string
+	}
+	tests := []struct {
+		name   string
+		policy Policy
+		steps  []step
+	}{
+		{
+			name: "always best",
+			steps: []step{
+				{map[string]float64{"A": 1, "B": 2}, "B", reasonBest},
+				{map[string]float64{"A": 3, "B": 2}, "A", reasonBest},
+				{map[string]float64{"A": 3, "B": 2}, "A", reasonBest},
+			},
+		},
+		{
+			name:   "threshold holds small gains",
+			policy: Policy{Threshold: 10},
+			steps: []step{
+				{map[string]float64{"A": 1.00, "B": 0.5}, "A", reasonBest},
+				{map[string]float64{"A": 1.00, "B": 1.05}, "A", reasonThreshold},
+				{map[string]float64{"A": 1.00, "B": 1.20}, "B", reasonBest},
+			},
+		},
+		{
+			name:   "dwell holds recent choice",
+			policy: Policy{MinDwell: 15 * time.Minute},
+			steps: []step{
+				{map[string]float64{"A": 2, "B": 1}, "A", reasonBest},
+				{map[string]float64{"A": 1, "B": 2}, "A", reasonDwell},
+				{map[string]float64{"A": 1, "B": 2}, "A", reasonDwell},
+				{map[string]float64{"A": 1, "B": 2}, "B", reasonBest}, // 15 min later
+			},
+		},
+		{
+			name:   "smoothing ignores a one-sample spike",
+			policy: Policy{Smoothing: 3},
+			steps: []step{
+				{map[string]float64{"A": 1, "B": 0.9}, "A", reasonBest},
+				{map[string]float64{"A": 1, "B": 0.9}, "A", reasonBest},
+				{map[string]float64{"A": 1, "B": 1.2}, "A", reasonBest}, // mean B = 1.0, not > A
+				{map[string]float64{"A": 1, "B": 1.2}, "B", reasonBest}, // mean B = 1.1
+			},
+		},
+		{
+			name:   "current coin missing stays",
+			policy: Policy{Threshold: 50},
+			steps: []step{
+				{map[string]float64{"A": 2, "B": 1}, "A", reasonBest},
+				{map[string]float64{"B": 1}, "A", reasonMissing},
+				{map[string]float64{"A": 0.5, "B": 1}, "B", reasonBest},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecider(tt.policy)
+			for i, s := range tt.steps {
+				now := t0.Add(time.Duration(i) * 5 * time.Minute)
+				dec := d.Decide(now, s.values)
+				if dec.Switch {
+					d.Commit(dec.Ticker, now)
+				}
+				if dec.Ticker != s.wantTicker || dec.Reason != s.wantReason {
+					t.Errorf("step %d: got %s/%s, want %s/%s", i, dec.Ticker, dec.Reason, s.wantTicker, s.wantReason)
+				}
+			}
+		})
+	}
+}
+
+func TestDeciderPinAndPause(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := NewDecider(Policy{})
+	d.Commit("A", now)
+
+	d.Paused = true
+	if dec := d.Decide(now, map[string]float64{"A": 1, "B": 2}); dec.Switch || dec.Ticker != "A" || dec.Reason != reasonPaused {
+		t.Errorf("paused: got %+v", dec)
+	}
+
+	d.Pinned = "C"
+	if dec := d.Decide(now, map[string]float64{"A": 1, "B": 2, "C": 0.5}); !dec.Switch || dec.Ticker != "C" || dec.Reason != reasonPinned {
+		t.Errorf("pinned: got %+v", dec)
+	}
+	// a pinned coin without data falls back to the normal policy
+	if dec := d.Decide(now, map[string]float64{"A": 1, "B": 2}); dec.Ticker != "A" || dec.Reason != reasonPaused {
+		t.Errorf("pinned coin missing: got %+v", dec)
+	}
+}
+
+func TestDeciderPayout(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := map[string]float64{"A": 1.00, "B": 0.95, "C": 0.80}
+	tests := []struct {
+		name       string
+		policy     Policy
+		days       map[string]float64
+		wantTicker string
+		wantReason string
+	}{
+		{"no thresholds", Policy{PayoutMaxDays: 7, PayoutFinishDays: 1, PayoutMargin: 10}, nil, "A", reasonThreshold},
+		{"avoid slow payout", Policy{PayoutMaxDays: 7}, map[string]float64{"A": 30, "B": 3}, "B", reasonPayout},
+		{"all slow keeps best", Policy{PayoutMaxDays: 7}, map[string]float64{"A": 30, "B": 30, "C": 30}, "A", reasonThreshold},
+		{"finish close payout", Policy{PayoutFinishDays: 1, PayoutMargin: 10}, map[string]float64{"B": 0.5}, "B", reasonPayout},
+		{"finish outside margin", Policy{PayoutFinishDays: 1, PayoutMargin: 10}, map[string]float64{"C": 0.5}, "A", reasonThreshold},
+		{"already reached", Policy{PayoutFinishDays: 1, PayoutMargin: 10}, map[string]float64{"B": 0}, "A", reasonThreshold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the threshold holds normal switches but not payout-driven ones
+			tt.policy.Threshold = 50
+			d := NewDecider(tt.policy)
+			d.Commit("C", now)
+			d.DaysToPayout = tt.days
+			dec := d.Decide(now, values)
+			if tt.wantReason == reasonPayout && !dec.Switch {
+				t.Errorf("payout choice did not switch: %+v", dec)
+			}
+			if dec.Best != tt.wantTicker || dec.Reason != tt.wantReason {
+				t.Errorf("got %s/%s, want %s/%s", dec.Best, dec.Reason, tt.wantTicker, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestBacktestAgainstFixed(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// A pays 1/day for the first half, B pays 1/day for the second half.
+	var snaps []Snapshot
+	for i := 0; i < 48; i++ {
+		a, b := 1.0, 0.5
+		if i >= 24 {
+			a, b = 0.5, 1.0
+		}
+		snaps = append(snaps, Snapshot{Time: t0.Add(time.Duration(i) * time.Hour), Coins: map[string]float64{"A": a, "B": b}})
+	}
+	interval := rawInterval(snaps)
+	res := backtest(snaps, Policy{}, interval)
+	if res.Switches != 1 {
+		t.Errorf("switches = %d, want 1", res.Switches)
+	}
+	if !approxEqual(res.Earnings, 2) {
+		t.Errorf("earnings = %g, want 2", res.Earnings)
+	}
+	fixed := fixedEarnings(snaps, interval)
+	if !approxEqual(fixed["A"], 1.5) || !approxEqual(fixed["B"], 1.5) {
+		t.Errorf("fixed = %v, want 1.5 each", fixed)
+	}
+}
+
+func TestBacktestAggregatedHistory(t *testing.T) {
+	// 11 days polled every 15 minutes; compaction leaves March as daily
+	// aggregates, April 1-3 as hourly ones and April 4 raw.
+	t0 := time.Date(2026, 3, 25, 0, 0, 0, 0, time.UTC)
+	now := t0.AddDate(0, 0, 11)
+	st := openTestStore(t, 1, 3, 0)
+	for at := t0; at.Before(now); at = at.Add(15 * time.Minute) {
+		appendAll(t, st, Snapshot{Time: at, Coins: map[string]float64{"A": 1, "B": 0.5}, Mining: "A"})
+	}
+	if err := st.compact(now); err != nil {
+		t.Fatal(err)
+	}
+	snaps, err := st.Query(t0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snaps[0].Samples != 96 || snaps[len(snaps)-1].Samples != 0 {
+		t.Fatalf("store not compacted as expected: first %+v, last %+v", snaps[0], snaps[len(snaps)-1])
+	}
+
+	interval := rawInterval(snaps)
+	if interval != 15*time.Minute {
+		t.Fatalf("raw interval = %v, want 15m", interval)
+	}
+	if res := backtest(snaps, Policy{}, interval); !approxEqual(res.Earnings, 11) {
+		t.Errorf("earnings = %g, want 11 (1/day for 11 days)", res.Earnings)
+	}
+	if fixed := fixedEarnings(snaps, interval); !approxEqual(fixed["A"], 11) || !approxEqual(fixed["B"], 5.5) {
+		t.Errorf("fixed = %v, want A 11, B 5.5", fixed)
+	}
+}
+
+func TestPolicyGrid(t *testing.T) {
+	configured := Policy{Threshold: 3, MinDwell: time.Hour, Smoothing: 0, PayoutMaxDays: 7}
+	tests := []struct {
+		name       string
+		ths        []float64
+		sms        []int
+		configured *Policy
+		want       int
+	}{
+		{"smoothing 0 and 1 are the same policy", []float64{0, 3}, []int{0, 1, 3}, nil, 4},
+		{"configured policy outside the grid is added", []float64{0}, []int{1}, &configured, 2},
+		{"configured policy in the grid is not repeated", []float64{0, 3}, []int{1}, &configured, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policyGrid(tt.ths, []time.Duration{time.Hour}, tt.sms, tt.configured)
+			if len(got) != tt.want {
+				t.Fatalf("got %d policies %+v, want %d", len(got), got, tt.want)
+			}
+			if tt.configured == nil {
+				return
+			}
+			found := false
+			for _, p := range got {
+				found = found || p == tt.configured.switching()
+			}
+			if !found {
+				t.Errorf("configured policy %+v missing from %+v", tt.configured.switching(), got)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// outputStyle controls how tables and charts are rendered on stdout.
+type outputStyle struct {
+	Color bool // emit ANSI color codes
+	ASCII bool // replace box-drawing and symbol characters with ASCII
+}
+
+// outStyle is the style used by printTable and printChart. It defaults to
+// full color and Unicode; configureOutput adjusts it for the terminal.
+var outStyle = outputStyle{Color: true}
+
+// Output modes for the daemon's per-cycle report.
+const (
+	outputFull    = "full"    // table, averages and chart
+	outputCompact = "compact" // one summary line per cycle
+	outputTUI     = "tui"     // interactive full-screen view
+	outputNone    = "none"    // logs only
+)
+
+// configureOutput sets outStyle. colorMode is auto, always or never; auto
+// enables color only when stdout is a terminal and NO_COLOR is unset. ASCII
+// is forced by ascii, and chosen automatically on dumb terminals and legacy
+// Windows consoles.
+func configureOutput(colorMode string, ascii bool) error {
+	tty := isTerminal(os.Stdout)
+	switch colorMode {
+	case "", "auto":
+		outStyle.Color = tty && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+	case "always":
+		outStyle.Color = true
+	case "never":
+		outStyle.Color = false
+	default:
+		return fmt.Errorf("invalid color mode %q (use auto, always or never)", colorMode)
+	}
+	outStyle.ASCII = ascii || os.Getenv("TERM") == "dumb" ||
+		(runtime.GOOS == "windows" && os.Getenv("WT_SESSION") == "" && os.Getenv("TERM_PROGRAM") == "")
+	return nil
+}
+
+// terminalSize returns the size of the terminal on stdout, if there is one.
+func terminalSize() (cols, rows int, ok bool) {
+	if !isTerminal(os.Stdout) {
+		return 0, 0, false
+	}
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, 0, false
+	}
+	if _, err := fmt.Sscan(string(out), &rows, &cols); err != nil || rows <= 0 || cols <= 0 {
+		return 0, 0, false
+	}
+	return cols, rows, true
+}
+
+// isTerminal reports whether f is a character device (a TTY or console).
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+var ansiPattern = regexp.MustCompile("\033\\[[0-9;]*m")
+
+var asciiReplacer = strings.NewReplacer(
+	"─", "-", "│", "|", "└", "+",
+	"╭", "/", "╯", "/", "╮", "\\", "╰", "\\",
+	"●", "*", "┊", ":", "★", "*", "⛏", "#", "⚡", "~", "→", "->",
+)
+
+// styledWriter applies an outputStyle to everything written through it.
+// Each Write must contain whole escape sequences, which holds for the
+// Fprintf-per-line rendering used here.
+type styledWriter struct {
+	w     io.Writer
+	style outputStyle
+}
+
+func (sw styledWriter) Write(p []byte) (int, error) {
+	s := string(p)
+	if !sw.style.Color {
+		s = ansiPattern.ReplaceAllString(s, "")
+	}
+	if sw.style.ASCII {
+		s = asciiReplacer.Replace(s)
+	}
+	if _, err := io.WriteString(sw.w, s); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// styled wraps w with outStyle, or returns w unchanged for the default style.
+func styled(w io.Writer) io.Writer {
+	if outStyle.Color && !outStyle.ASCII {
+		return w
+	}
+	return styledWriter{w: w, style: outStyle}
+}
+
+// printSummary prints the compact one-line report.
+func printSummary(profs []CoinProfitability, fiat string, currentTicker string, hashrate int) {
+	writeSummary(styled(os.Stdout), time.Now(), profs, fiat, currentTicker, hashrate)
+}
+
+// writeSummary renders one line: time, hashrate and every coin's daily fiat
+// revenue in ranking order, with the mined coin starred.
+func writeSummary(w io.Writer, at time.Time, profs []CoinProfitability, fiat string, currentTicker string, hashrate int) {
+	parts := make([]string, 0, len(profs))
+	for _, p := range profs {
+		marker := ""
+		if p.Ticker == currentTicker {
+			marker = "★"
+		}
+		part := fmt.Sprintf("%s%s %.8f", marker, p.Ticker, p.DailyRevenueFiat)
+		if st := p.dataStatus(); st != "" {
+			part += " (" + st + ")"
+		}
+		parts = append(parts, part)
+	}
+	fmt.Fprintf(w, "%s  ⚡ %s  %s %s/day\n", at.Format("2006-01-02 15:04:05"), formatHashrate(float64(hashrate)),
+		strings.Join(parts, " | "), strings.ToUpper(fiat))
+}
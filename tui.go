@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// tuiWindows are the chart windows cycled with the w key (0 = whole history).
+var tuiWindows = []time.Duration{0, time.Hour, 6 * time.Hour, 24 * time.Hour}
+
+// tuiEventLines is how many log lines the event pane keeps.
+const tuiEventLines = 100
+
+// tuiAction tells the run loop what to do after a key press.
+type tuiAction int
+
+const (
+	tuiNone    tuiAction = iota
+	tuiRefresh           // run a cycle now
+	tuiQuit              // stop the daemon
+)
+
+// tui is the live full-screen view of `run -output tui`. The run loop feeds
+// it with Update and SetWorkers and redraws it with Draw; logs are routed to
+// its event pane.
+type tui struct {
+	fiat   string
+	keys   chan byte
+	resize chan struct{}
+	saved  string // stty state restored by Stop
+
+	mu            sync.Mutex
+	width, height int
+	events        []string
+
+	at       time.Time
+	profs    []CoinProfitability
+	current  string
+	hashrate int
+	snaps    []Snapshot
+	avgs     []CoinAverage
+	mined    MinedAverage
+	workers  []Worker
+	coinByID map[string]string
+
+	btc    bool
+	window int // index into tuiWindows
+	paused bool
+	pinned string
+}
+
+func newTUI(cfg *Config) *tui {
+	return &tui{
+		fiat:     cfg.FiatCurrency,
+		keys:     make(chan byte, 16),
+		resize:   make(chan struct{}, 1),
+		width:    80,
+		height:   24,
+		coinByID: profileTickers(cfg),
+		btc:      cfg.ChartUnits == "btc",
+	}
+}
+
+// Start switches the terminal to unbuffered input on the alternate screen.
+// Stop must be called to restore it.
+func (t *tui) Start() error {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return errors.New("the TUI needs an interactive terminal on stdin and stdout")
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return fmt.Errorf("save terminal state: %w", err)
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return fmt.Errorf("set terminal mode: %w", err)
+	}
+	t.saved = strings.TrimSpace(saved)
+	t.readSize()
+	fmt.Fprint(os.Stdout, "\033[?1049h\033[?25l")
+	go t.readKeys()
+	go t.watchSize()
+	return nil
+}
+
+// Stop leaves the alternate screen and restores the terminal state.
+func (t *tui) Stop() {
+	fmt.Fprint(os.Stdout, "\033[?25h\033[?1049l")
+	if t.saved != "" {
+		stty(t.saved)
+	}
+}
+
+// Keys returns key presses; it is nil (blocks forever) when t is nil.
+func (t *tui) Keys() <-chan byte {
+	if t == nil {
+		return nil
+	}
+	return t.keys
+}
+
+// Resized signals terminal size changes; it is nil when t is nil.
+func (t *tui) Resized() <-chan struct{} {
+	if t == nil {
+		return nil
+	}
+	return t.resize
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func (t *tui) readKeys() {
+	buf := make([]byte, 1)
+	for {
+		if _, err := os.Stdin.Read(buf); err != nil {
+			return
+		}
+		t.keys <- buf[0]
+	}
+}
+
+// readSize queries the terminal size and reports whether it changed.
+func (t *tui) readSize() bool {
+	cols, rows, ok := terminalSize()
+	if !ok {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	changed := rows != t.height || cols != t.width
+	t.height, t.width = rows, cols
+	return changed
+}
+
+// watchSize polls the terminal size; SIGWINCH is not available everywhere.
+func (t *tui) watchSize() {
+	for range time.Tick(time.Second) {
+		if t.readSize() {
+			select {
+			case t.resize <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// LogWriter returns the writer the logger should use while the TUI runs.
+// Lines go to the event pane, and to stderr as well when it is redirected.
+func (t *tui) LogWriter() io.Writer {
+	if isTerminal(os.Stderr) {
+		return tuiLog{t}
+	}
+	return io.MultiWriter(tuiLog{t}, os.Stderr)
+}
+
+type tuiLog struct{ t *tui }
+
+func (l tuiLog) Write(p []byte) (int, error) {
+	l.t.mu.Lock()
+	defer l.t.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		l.t.events = append(l.t.events, shortLogLine(line))
+	}
+	if len(l.t.events) > tuiEventLines {
+		l.t.events = l.t.events[len(l.t.events)-tuiEventLines:]
+	}
+	return len(p), nil
+}
+
+// shortLogLine replaces the leading time=<RFC3339> of a text log line with
+// the time of day.
+func shortLogLine(line string) string {
+	rest, ok := strings.CutPrefix(line, "time=")
+	if !ok {
+		return line
+	}
+	ts, rest, _ := strings.Cut(rest, " ")
+	at, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return line
+	}
+	return at.Format("15:04:05") + " " + rest
+}
+
+// Update records the result of a cycle.
+func (t *tui) Update(at time.Time, profs []CoinProfitability, current string, hashrate int, hist *History) {
+	avgs, mined := hist.Averages()
+	snaps := hist.All()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.at, t.profs, t.current, t.hashrate = at, profs, current, hashrate
+	t.snaps, t.avgs, t.mined = snaps, avgs, mined
+}
+
+// SetConfig picks up a reloaded config.
+func (t *tui) SetConfig(cfg *Config) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fiat, t.coinByID = cfg.FiatCurrency, profileTickers(cfg)
+}
+
+// SetWorkers records the latest worker list.
+func (t *tui) SetWorkers(workers []Worker) {
+	sort.Slice(workers, func(i, j int) bool { return workers[i].Name < workers[j].Name })
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.workers = workers
+}
+
+// HandleKey applies a key press to the view or to dec.
+func (t *tui) HandleKey(key byte, dec *Decider) tuiAction {
+	t.mu.Lock()
+	profs := t.profs
+	t.mu.Unlock()
+
+	switch key {
+	case 'q', 'Q':
+		return tuiQuit
+	case 'r', 'R':
+		return tuiRefresh
+	case 'p', 'P':
+		dec.Paused = !dec.Paused
+		t.setFlags(dec)
+		if dec.Paused {
+			slog.Info("Switching paused", "ticker", dec.Current)
+		} else {
+			slog.Info("Switching resumed")
+		}
+	case 'u', 'U':
+		t.mu.Lock()
+		t.btc = !t.btc
+		t.mu.Unlock()
+	case 'w', 'W':
+		t.mu.Lock()
+		t.window = (t.window + 1) % len(tuiWindows)
+		t.mu.Unlock()
+	case '0':
+		if dec.Pinned == "" {
+			return tuiNone
+		}
+		slog.Info("Unpinned coin", "ticker", dec.Pinned)
+		dec.Pinned = ""
+		t.setFlags(dec)
+		return tuiRefresh
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		n := int(key - '1')
+		if n >= len(profs) {
+			return tuiNone
+		}
+		dec.Pinned = profs[n].Ticker
+		t.setFlags(dec)
+		slog.Info("Pinned coin", "ticker", dec.Pinned)
+		return tuiRefresh
+	}
+	return tuiNone
+}
+
+func (t *tui) setFlags(dec *Decider) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.paused, t.pinned = dec.Paused, dec.Pinned
+}
+
+// Draw repaints the whole screen.
+func (t *tui) Draw() {
+	var buf bytes.Buffer
+	buf.WriteString("\033[H")
+	lines := t.render()
+	for i, l := range lines {
+		buf.WriteString(l)
+		buf.WriteString("\033[K")
+		if i < len(lines)-1 {
+			buf.WriteString("\n")
+		}
+	}
+	buf.WriteString("\033[J")
+	styled(os.Stdout).Write(buf.Bytes())
+}
+
+// render lays out the panes for the current terminal size.
+func (t *tui) render() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	width, height := t.width, t.height
+
+	// Header
+	title := fmt.Sprintf(" %sProfit switcher%s", colorBold, colorReset)
+	if !t.at.IsZero() {
+		title += fmt.Sprintf("  %s  ⚡ %s  mining %s", t.at.Format("2006-01-02 15:04:05"), formatHashrate(float64(t.hashrate)), t.current)
+	}
+	if t.paused {
+		title += "  " + colorBold + "[PAUSED]" + colorReset
+	}
+	if t.pinned != "" {
+		title += "  " + colorBold + "[PINNED " + t.pinned + "]" + colorReset
+	}
+	lines := []string{title, strings.Repeat("─", width)}
+
+	// Ranking and averages on the left, workers on the right (or below when narrow)
+	left := t.rankingPane()
+	right := t.workersPane()
+	const leftWidth = 50
+	if width >= leftWidth+40 {
+		for i := 0; i < len(left) || i < len(right); i++ {
+			l, r := "", ""
+			if i < len(left) {
+				l = left[i]
+			}
+			if i < len(right) {
+				r = right[i]
+			}
+			lines = append(lines, padLine(l, leftWidth)+"│ "+r)
+		}
+	} else {
+		lines = append(lines, left...)
+		lines = append(lines, right...)
+	}
+	lines = append(lines, strings.Repeat("─", width))
+
+	// Event log and key help at the bottom; the chart gets what is left
+	events := t.events
+	if len(events) > 6 {
+		events = events[len(events)-6:]
+	}
+	footer := []string{strings.Repeat("─", width), fmt.Sprintf(" %sEvents%s", colorBold, colorReset)}
+	for _, e := range events {
+		footer = append(footer, " "+e)
+	}
+	footer = append(footer, fmt.Sprintf(" %s[1-9] pin rank  [0] unpin  [p] pause  [u] units  [w] window  [r] refresh  [q] quit%s", colorDim, colorReset))
+
+	// The chart prints a title, axis, labels and legend around its plot rows.
+	if rows := height - len(lines) - len(footer) - 5; rows >= 3 {
+		var chart bytes.Buffer
+		writeChart(&chart, t.snaps, t.fiat, chartOptions{
+			BTC:    t.btc,
+			Window: tuiWindows[t.window],
+			Width:  width - 16,
+			Height: rows,
+		})
+		label := "all"
+		if w := tuiWindows[t.window]; w > 0 {
+			label = strings.TrimSuffix(w.String(), "0m0s")
+		}
+		out := strings.Trim(chart.String(), "\n")
+		if out == "" {
+			out = "  Not enough history for a chart yet"
+		}
+		chartLines := strings.Split(out, "\n")
+		chartLines[0] += fmt.Sprintf("  %swindow: %s%s", colorDim, label, colorReset)
+		lines = append(lines, chartLines...)
+	}
+	for len(lines)+len(footer) < height {
+		lines = append(lines, "")
+	}
+	lines = append(lines, footer...)
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	for i, l := range lines {
+		lines[i] = fitLine(l, width)
+	}
+	return lines
+}
+
+func (t *tui) rankingPane() []string {
+	currency := strings.ToUpper(t.fiat)
+	lines := []string{fmt.Sprintf(" %s%-3s %-10s %14s %14s%s", colorBold, "#", "Coin", currency+"/day", "BTC/MH/day", colorReset)}
+	for i, p := range t.profs {
+		line := fmt.Sprintf(" %-3d %s%-8s %14.8f %14.10f", i+1, t.marker(p.Ticker), p.Ticker, p.DailyRevenueFiat, p.BTCPerMHDay)
+		if st := p.dataStatus(); st != "" {
+			line += fmt.Sprintf(" %s%s%s", colorDim, st, colorReset)
+		}
+		lines = append(lines, line)
+	}
+	if len(t.avgs) > 0 && t.avgs[0].Count > 1 {
+		lines = append(lines, "", fmt.Sprintf(" %sAverages (%d samples)%s", colorBold, t.avgs[0].Count, colorReset))
+		for _, a := range t.avgs {
+			lines = append(lines, fmt.Sprintf("     %s%-8s %14.8f %14.10f", t.marker(a.Ticker), a.Ticker, a.AvgFiat, a.AvgBTCMH))
+		}
+		if t.mined.Count > 0 {
+			lines = append(lines, fmt.Sprintf("     ⛏ %-8s %14.8f %14.10f", "MINED", t.mined.AvgFiat, t.mined.AvgBTCMH))
+		}
+	}
+	return lines
+}
+
+func (t *tui) marker(ticker string) string {
+	if ticker == t.current {
+		return "★ "
+	}
+	return "  "
+}
+
+func (t *tui) workersPane() []string {
+	var total uint64
+	for _, w := range t.workers {
+		total += w.Hashrate
+	}
+	lines := []string{fmt.Sprintf("%sWorkers (%d, %s)%s", colorBold, len(t.workers), formatHashrate(float64(total)), colorReset)}
+	for _, w := range t.workers {
+		coin := t.coinByID[w.ProfileID]
+		if coin == "" {
+			coin = "?"
+		}
+		lines = append(lines, fmt.Sprintf("%-18s %-8s %12s  %s", w.Name, w.Status, formatHashrate(float64(w.Hashrate)), coin))
+	}
+	return lines
+}
+
+// visibleLen is the display width of s, ignoring ANSI color sequences.
+func visibleLen(s string) int {
+	return utf8.RuneCountInString(ansiPattern.ReplaceAllString(s, ""))
+}
+
+// padLine pads s with spaces to width display columns, truncating if longer.
+func padLine(s string, width int) string {
+	s = fitLine(s, width)
+	return s + strings.Repeat(" ", width-visibleLen(s))
+}
+
+// fitLine truncates s to width display columns, keeping color sequences
+// intact and resetting the color when anything was cut.
+func fitLine(s string, width int) string {
+	if visibleLen(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(s) && n < width; {
+		if loc := ansiPattern.FindStringIndex(s[i:]); loc != nil && loc[0] == 0 {
+			b.WriteString(s[i : i+loc[1]])
+			i += loc[1]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteRune(r)
+		i += size
+		n++
+	}
+	b.WriteString(colorReset)
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTUIRenderFitsTerminal(t *testing.T) {
+	ui := newTUI(&Config{FiatCurrency: "eur"})
+	hist := NewHistory(100)
+	for _, s := range testSnapshots() {
+		hist.Add(s)
+	}
+	profs := []CoinProfitability{
+		{Ticker: "XTM", DailyRevenueFiat: 0.31, BTCPerMHDay: 0.0005},
+		{Ticker: "SAL", DailyRevenueFiat: 0.25, BTCPerMHDay: 0.0004},
+	}
+	ui.Update(time.Date(2026, 2, 23, 18, 8, 0, 0, time.UTC), profs, "XTM", 8070, hist)
+	ui.SetWorkers([]Worker{{Name: "rig01", Status: "online", Hashrate: 8070}})
+	tuiLog{ui}.Write([]byte("time=2026-02-23T18:08:00.000Z level=INFO msg=Switching ticker=XTM\n"))
+
+	for _, size := range [][2]int{{120, 40}, {80, 24}, {40, 10}} {
+		ui.width, ui.height = size[0], size[1]
+		lines := ui.render()
+		if len(lines) != ui.height {
+			t.Errorf("%dx%d: got %d lines", size[0], size[1], len(lines))
+		}
+		for i, l := range lines {
+			if n := visibleLen(l); n > ui.width {
+				t.Errorf("%dx%d: line %d is %d columns wide: %q", size[0], size[1], i, n, l)
+			}
+		}
+	}
+	if got := ui.events[0]; got != "18:08:00 level=INFO msg=Switching ticker=XTM" {
+		t.Errorf("event line = %q", got)
+	}
+}
+
+func TestTUIHandleKey(t *testing.T) {
+	ui := newTUI(&Config{})
+	ui.profs = []CoinProfitability{{Ticker: "XTM"}, {Ticker: "SAL"}}
+	dec := NewDecider(Policy{})
+
+	if a := ui.HandleKey('2', dec); a != tuiRefresh || dec.Pinned != "SAL" {
+		t.Errorf("pin: action %d, pinned %q", a, dec.Pinned)
+	}
+	if a := ui.HandleKey('9', dec); a != tuiNone || dec.Pinned != "SAL" {
+		t.Errorf("pin out of range: action %d, pinned %q", a, dec.Pinned)
+	}
+	if a := ui.HandleKey('0', dec); a != tuiRefresh || dec.Pinned != "" {
+		t.Errorf("unpin: action %d, pinned %q", a, dec.Pinned)
+	}
+	ui.HandleKey('p', dec)
+	if !dec.Paused || !ui.paused {
+		t.Error("p did not pause switching")
+	}
+	ui.HandleKey('u', dec)
+	ui.HandleKey('w', dec)
+	if !ui.btc || ui.window != 1 {
+		t.Errorf("units/window: btc=%v window=%d", ui.btc, ui.window)
+	}
+	if a := ui.HandleKey('q', dec); a != tuiQuit {
+		t.Errorf("q: action %d", a)
+	}
+}
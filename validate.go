@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// cmdValidate implements the `validate` subcommand: strict offline checks of the
+// config followed by online checks against Ultimate Proxy and Kryptex.
+// It returns the process exit code.
+func cmdValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config YAML file")
+	shortConfig := fs.String("c", "", "Path to config YAML file (shorthand)")
+	offline := fs.Bool("offline", false, "Only check the config file, do not call the APIs")
+	fs.Parse(args)
+
+	if *shortConfig != "" {
+		configPath = shortConfig
+	}
+
+	cfg, err := loadConfig(*configPath, true)
+	if err != nil {
+		fmt.Printf("✗ %s is invalid:\n", *configPath)
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Printf("    - %s\n", line)
+		}
+		return 1
+	}
+	httpClient = cfg.httpClient
+	fmt.Printf("✓ %s: %d coin(s), algorithm %s\n", *configPath, len(cfg.Coins), cfg.ProxyAlgorithm)
+	if *offline {
+		return 0
+	}
+
+	errs := checkOnline(cfg)
+	for _, err := range errs {
+		fmt.Printf("✗ %v\n", err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	fmt.Println("✓ all profiles, rates and revenue tickers resolve")
+	return 0
+}
+
+// checkOnline verifies that every configured profile exists on Ultimate Proxy,
+// every ticker has a Kryptex rate and every revenue ticker returns a revenue.
+func checkOnline(cfg *Config) []error {
+	var errs []error
+
+	profiles, err := fetchAllProfiles(cfg.ProxyBaseURL, cfg.ProxyAPIKey)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("list Ultimate Proxy profiles: %w", err))
+	} else {
+		known := make(map[string]bool, len(profiles))
+		for _, p := range profiles {
+			known[p.ID] = true
+		}
+		for _, c := range cfg.Coins {
+			if !known[c.ProfileID] {
+				errs = append(errs, fmt.Errorf("%s: profile %s not found on Ultimate Proxy", c.Ticker, c.ProfileID))
+			}
+		}
+	}
+
+	rates, err := fetchRates(cfg.KryptexBaseURL)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		if _, ok := rates.Fiat[strings.ToUpper(cfg.FiatCurrency)]; !ok {
+			errs = append(errs, fmt.Errorf("fiat_currency %s has no Kryptex rate", cfg.FiatCurrency))
+		}
+		for _, c := range cfg.Coins {
+			if _, ok := rates.Crypto[c.Ticker]; !ok {
+				errs = append(errs, fmt.Errorf("%s: no Kryptex rate for ticker", c.Ticker))
+			}
+		}
+	}
+
+	for _, c := range cfg.Coins {
+		revTicker := c.Ticker
+		if c.RevenueTicker != "" {
+			revTicker = c.RevenueTicker
+		}
+		if _, err := fetchDailyRevenue(cfg.KryptexBaseURL, revTicker, cfg.DefaultHashrate); err != nil {
+			var hint string
+			if c.RevenueTicker == "" {
+				hint = " (set revenue_ticker if Kryptex uses a different name)"
+			}
+			errs = append(errs, fmt.Errorf("%s: revenue ticker %s does not resolve%s: %w", c.Ticker, revTicker, hint, err))
+		}
+	}
+	return errs
+}